@@ -3,7 +3,9 @@ package actions
 import (
 	"testing"
 
+	"github.com/gobuffalo/buffalo/mail"
 	"github.com/gobuffalo/suite"
+	"github.com/nicomo/kumano/mailers"
 )
 
 type ActionSuite struct {
@@ -14,3 +16,14 @@ func Test_ActionSuite(t *testing.T) {
 	as := &ActionSuite{suite.NewAction(App())}
 	suite.Run(t, as)
 }
+
+// SetupTest gives each test a clean DB and an empty in-memory mail sender
+func (as *ActionSuite) SetupTest() {
+	as.Action.SetupTest()
+	mailers.Sender = &mailers.MemorySender{}
+}
+
+// Mails returns the mails sent so far during the test
+func (as *ActionSuite) Mails() []mail.Message {
+	return mailers.Sender.(*mailers.MemorySender).Messages()
+}
@@ -0,0 +1,526 @@
+package actions
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// The JSON API lives under /api/v1. It exposes texts, users, stars and drafts
+// with their own representations, so that field names stay stable whatever
+// happens to the models, and answers every error with the same envelope:
+//
+//	{"error": {"status": 422, "message": "...", "fields": {"title": ["..."]}}}
+//
+// Clients authenticate with an "Authorization: Bearer <token>" header.
+
+// apiUser is the public representation of a models.User
+type apiUser struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Nickname   string    `json:"nickname"`
+	Bio        string    `json:"bio"`
+	AvatarURL  string    `json:"avatar_url"`
+	SignedUpAt time.Time `json:"signed_up_at"`
+}
+
+func newAPIUser(u models.User) apiUser {
+	return apiUser{
+		ID:         u.ID,
+		Name:       u.Name.String,
+		Nickname:   u.Nickname.String,
+		Bio:        u.Bio.String,
+		AvatarURL:  u.AvatarURL.String,
+		SignedUpAt: u.SignedUpAt,
+	}
+}
+
+// apiText is the public representation of a models.Text
+type apiText struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Draft       bool       `json:"draft"`
+	Scheduled   bool       `json:"scheduled"`
+	AuthorID    uuid.UUID  `json:"author_id"`
+	Author      *apiUser   `json:"author,omitempty"`
+	StarsCount  int        `json:"stars_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	PublishedAt *time.Time `json:"published_at"`
+	Tags        []string   `json:"tags"`
+}
+
+func newAPIText(t models.Text) apiText {
+	at := apiText{
+		ID:         t.ID,
+		Title:      t.Title,
+		Content:    t.Content,
+		Draft:      t.Draft,
+		Scheduled:  t.Scheduled(),
+		AuthorID:   t.AuthorID,
+		StarsCount: len(t.StarredBy),
+		CreatedAt:  t.CreatedAt,
+		UpdatedAt:  t.UpdatedAt,
+		Tags:       []string{},
+	}
+	for _, tag := range t.Tags {
+		at.Tags = append(at.Tags, tag.Name)
+	}
+	if t.PublishedAt.Valid {
+		at.PublishedAt = &t.PublishedAt.Time
+	}
+	if t.Author.ID != uuid.Nil {
+		author := newAPIUser(t.Author)
+		at.Author = &author
+	}
+	return at
+}
+
+func newAPITexts(texts models.Texts) []apiText {
+	ats := make([]apiText, 0, len(texts))
+	for _, t := range texts {
+		ats = append(ats, newAPIText(t))
+	}
+	return ats
+}
+
+func newAPIUsers(users models.Users) []apiUser {
+	aus := make([]apiUser, 0, len(users))
+	for _, u := range users {
+		aus = append(aus, newAPIUser(u))
+	}
+	return aus
+}
+
+// apiPagination is the pagination metadata sent along with lists
+type apiPagination struct {
+	Page         int `json:"page"`
+	PerPage      int `json:"per_page"`
+	TotalEntries int `json:"total_entries"`
+	TotalPages   int `json:"total_pages"`
+}
+
+func newAPIPagination(p *pop.Paginator) apiPagination {
+	return apiPagination{
+		Page:         p.Page,
+		PerPage:      p.PerPage,
+		TotalEntries: p.TotalEntriesSize,
+		TotalPages:   p.TotalPages,
+	}
+}
+
+// apiTextParams are the fields a client may send to create or update a text,
+// a publish_at date in the future schedules the text instead of publishing it
+type apiTextParams struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Draft     bool      `json:"draft"`
+	PublishAt time.Time `json:"publish_at"`
+	Tags      *[]string `json:"tags"` // left out, the tags don't change
+}
+
+// apiError renders the error envelope
+func apiError(c buffalo.Context, status int, message string) error {
+	return c.Render(status, r.JSON(map[string]interface{}{
+		"error": map[string]interface{}{
+			"status":  status,
+			"message": message,
+		},
+	}))
+}
+
+// apiValidationError renders the error envelope with the validation errors per field
+func apiValidationError(c buffalo.Context, verrs *validate.Errors) error {
+	return c.Render(422, r.JSON(map[string]interface{}{
+		"error": map[string]interface{}{
+			"status":  422,
+			"message": "validation failed",
+			"fields":  verrs.Errors,
+		},
+	}))
+}
+
+// apiPostingAllowed checks the user is within her posting quota,
+// rendering a 429 error telling her how long to wait when she isn't
+func apiPostingAllowed(c buffalo.Context, tx *pop.Connection, user *models.User) (bool, error) {
+	ok, wait, err := user.CanPost(tx)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if ok {
+		return true, nil
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	return false, c.Render(429, r.JSON(map[string]interface{}{
+		"error": map[string]interface{}{
+			"status":      429,
+			"message":     slowDown(c, wait),
+			"retry_after": int(wait.Seconds()) + 1,
+		},
+	}))
+}
+
+// apiList renders a page of results along with its pagination metadata
+func apiList(c buffalo.Context, data interface{}, p *pop.Paginator) error {
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"data":       data,
+		"pagination": newAPIPagination(p),
+	}))
+}
+
+// APIAuthenticate is a middleware making sure /api/v1 routes are only used
+// with an "Authorization: Bearer <token>" header, resolved by SetCurrentUser.
+// Session cookies aren't accepted here since the API skips CSRF protection.
+func APIAuthenticate(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if ok, _ := c.Value("token_authenticated").(bool); !ok {
+			return apiError(c, 401, "missing bearer token")
+		}
+		return next(c)
+	}
+}
+
+// APITextsResource exposes texts through the JSON API
+type APITextsResource struct {
+	buffalo.Resource
+}
+
+// List gets published texts. This function is mapped to the path
+// GET /api/v1/texts
+func (v APITextsResource) List(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	texts := models.Texts{}
+	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND hidden = ?", false, false).Order("published_at desc")
+	if err := q.Eager().All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPITexts(texts), q.Paginator)
+}
+
+// Drafts gets the current user's drafts. This function is mapped to the path
+// GET /api/v1/drafts
+func (v APITextsResource) Drafts(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+
+	texts := models.Texts{}
+	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND author_id = ?", true, user.ID).Order("created_at desc")
+	if err := q.Eager().All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPITexts(texts), q.Paginator)
+}
+
+// Show gets one text. Drafts and hidden texts are only visible to their author.
+// This function is mapped to the path GET /api/v1/texts/{text_id}
+func (v APITextsResource) Show(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	text := &models.Text{}
+	if err := tx.Eager().Find(text, c.Param("text_id")); err != nil {
+		return apiError(c, 404, "text not found")
+	}
+	user := c.Value("current_user").(*models.User)
+	if (text.Draft || text.Hidden) && !user.CanManage(text.AuthorID) {
+		return apiError(c, 404, "text not found")
+	}
+
+	return c.Render(200, r.JSON(newAPIText(*text)))
+}
+
+// Create adds a text. This function is mapped to the path
+// POST /api/v1/texts
+func (v APITextsResource) Create(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+
+	params := &apiTextParams{}
+	if err := c.Bind(params); err != nil {
+		return apiError(c, 400, "malformed request body")
+	}
+
+	text := &models.Text{
+		Title:    params.Title,
+		Content:  params.Content,
+		Draft:    params.Draft,
+		AuthorID: user.ID,
+	}
+	if !text.Draft && !params.PublishAt.After(time.Now()) {
+		if ok, err := apiPostingAllowed(c, tx, user); !ok {
+			return err
+		}
+	}
+	live := false
+	if !text.Draft {
+		live = publishOrSchedule(text, params.PublishAt)
+	}
+
+	verrs, err := tx.ValidateAndCreate(text)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return apiValidationError(c, verrs)
+	}
+
+	if err := models.RecordRevision(tx, text, user.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	if params.Tags != nil {
+		text.Tags = models.ParseTags(strings.Join(*params.Tags, ","))
+		if err := models.SaveTextTags(tx, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if live {
+		if err := models.TextWentLive(tx, user, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return c.Render(201, r.JSON(newAPIText(*text)))
+}
+
+// Update changes a text, only its author or an admin may do so.
+// This function is mapped to the path PUT /api/v1/texts/{text_id}
+func (v APITextsResource) Update(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return apiError(c, 404, "text not found")
+	}
+	if !user.CanManage(text.AuthorID) {
+		return apiError(c, 403, "only the author can update a text")
+	}
+	author, err := textAuthor(c, tx, text)
+	if err != nil {
+		return err
+	}
+
+	params := &apiTextParams{}
+	if err := c.Bind(params); err != nil {
+		return apiError(c, 400, "malformed request body")
+	}
+
+	wasLive := !text.Draft
+	text.Title = params.Title
+	text.Content = params.Content
+	text.Draft = params.Draft
+	// texts that went live before already counted against the quota
+	if !text.Draft && !wasLive && !text.FirstPublishedAt.Valid && !params.PublishAt.After(time.Now()) {
+		if ok, err := apiPostingAllowed(c, tx, author); !ok {
+			return err
+		}
+	}
+	live := false
+	if text.Draft {
+		text.PublishedAt = nulls.Time{}
+	} else if !wasLive {
+		live = publishOrSchedule(text, params.PublishAt)
+	}
+
+	verrs, err := tx.ValidateAndUpdate(text)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return apiValidationError(c, verrs)
+	}
+
+	if err := models.RecordRevision(tx, text, user.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	if params.Tags != nil {
+		text.Tags = models.ParseTags(strings.Join(*params.Tags, ","))
+		if err := models.SaveTextTags(tx, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if live {
+		if err := models.TextWentLive(tx, author, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return c.Render(200, r.JSON(newAPIText(*text)))
+}
+
+// Destroy deletes a text, only its author or an admin may do so.
+// This function is mapped to the path DELETE /api/v1/texts/{text_id}
+func (v APITextsResource) Destroy(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return apiError(c, 404, "text not found")
+	}
+	if !user.CanManage(text.AuthorID) {
+		return apiError(c, 403, "only the author can delete a text")
+	}
+
+	if err := models.DeleteText(tx, text); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(newAPIText(*text)))
+}
+
+// Stars lists the users who starred a text. This function is mapped to the path
+// GET /api/v1/texts/{text_id}/stars
+func (v APITextsResource) Stars(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil || text.Draft || text.Hidden {
+		return apiError(c, 404, "text not found")
+	}
+
+	users := models.Users{}
+	q := tx.PaginateFromParams(c.Params()).Where("id IN (SELECT user_id FROM stars WHERE text_id = ?)", text.ID)
+	if err := q.All(&users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPIUsers(users), q.Paginator)
+}
+
+// Star stars a text, starring twice is harmless.
+// This function is mapped to the path POST /api/v1/texts/{text_id}/star
+func (v APITextsResource) Star(c buffalo.Context) error {
+	return v.setStar(c, true)
+}
+
+// Unstar takes back a star, unstarring twice is harmless.
+// This function is mapped to the path DELETE /api/v1/texts/{text_id}/star
+func (v APITextsResource) Unstar(c buffalo.Context) error {
+	return v.setStar(c, false)
+}
+
+func (v APITextsResource) setStar(c buffalo.Context, starred bool) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := c.Value("current_user").(*models.User)
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil || text.Draft || text.Hidden {
+		return apiError(c, 404, "text not found")
+	}
+	if text.AuthorID == user.ID {
+		return apiError(c, 403, "you can't star your own text")
+	}
+
+	var err error
+	if starred {
+		err = starText(tx, user, text)
+	} else {
+		_, err = models.UnstarText(tx, user.ID, text)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	count, err := models.CountStars(tx, text.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"starred": starred,
+		"count":   count,
+	}))
+}
+
+// APIUsersResource exposes users through the JSON API
+type APIUsersResource struct {
+	buffalo.Resource
+}
+
+// List gets all users who signed up. This function is mapped to the path
+// GET /api/v1/users
+func (v APIUsersResource) List(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	users := models.Users{}
+	// pending invitations aren't users yet
+	q := tx.PaginateFromParams(c.Params()).Where("provider_id IS NOT NULL").Order("signedup_at asc")
+	if err := q.All(&users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPIUsers(users), q.Paginator)
+}
+
+// Show gets one user. This function is mapped to the path
+// GET /api/v1/users/{user_id}
+func (v APIUsersResource) Show(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil || !user.ProviderID.Valid {
+		return apiError(c, 404, "user not found")
+	}
+
+	return c.Render(200, r.JSON(newAPIUser(*user)))
+}
+
+// Me gets the user the token belongs to. This function is mapped to the path
+// GET /api/v1/me
+func (v APIUsersResource) Me(c buffalo.Context) error {
+	return c.Render(200, r.JSON(newAPIUser(*c.Value("current_user").(*models.User))))
+}
+
+// Texts gets a user's published texts. This function is mapped to the path
+// GET /api/v1/users/{user_id}/texts
+func (v APIUsersResource) Texts(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return apiError(c, 404, "user not found")
+	}
+
+	texts := models.Texts{}
+	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND hidden = ? AND author_id = ?", false, false, user.ID).Order("published_at desc")
+	if err := q.Eager().All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPITexts(texts), q.Paginator)
+}
+
+// Starred gets the published texts a user starred. This function is mapped to the path
+// GET /api/v1/users/{user_id}/starred
+func (v APIUsersResource) Starred(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return apiError(c, 404, "user not found")
+	}
+
+	texts := models.Texts{}
+	q := tx.PaginateFromParams(c.Params()).
+		Where("draft = ? AND hidden = ? AND id IN (SELECT text_id FROM stars WHERE user_id = ?)", false, false, user.ID).
+		Order("published_at desc")
+	if err := q.Eager().All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return apiList(c, newAPITexts(texts), q.Paginator)
+}
@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"regexp"
+
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_API_RequiresToken() {
+	res := as.JSON("/api/v1/texts").Get()
+	as.Equal(401, res.Code)
+	as.Contains(res.Body.String(), `"error"`)
+	as.Contains(res.Body.String(), "missing bearer token")
+
+	req := as.JSON("/api/v1/texts")
+	req.Headers["Authorization"] = "Bearer nope"
+	res = req.Get()
+	as.Equal(401, res.Code)
+}
+
+func (as *ActionSuite) Test_API_TextsList() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	t, plain, err := models.NewAPIToken(u.ID, "test")
+	as.NoError(err)
+	as.NoError(as.DB.Create(t))
+	as.NoError(as.DB.Create(&models.Text{Title: "Published", AuthorID: u.ID}))
+	as.NoError(as.DB.Create(&models.Text{Title: "Draft", AuthorID: u.ID, Draft: true}))
+
+	req := as.JSON("/api/v1/texts")
+	req.Headers["Authorization"] = "Bearer " + plain
+	res := req.Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), `"title":"Published"`)
+	as.NotContains(res.Body.String(), `"title":"Draft"`)
+	as.Contains(res.Body.String(), `"total_entries":1`)
+}
+
+func (as *ActionSuite) Test_API_NotFound() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	t, plain, err := models.NewAPIToken(u.ID, "test")
+	as.NoError(err)
+	as.NoError(as.DB.Create(t))
+
+	req := as.JSON("/api/v1/texts/00000000-0000-0000-0000-000000000000")
+	req.Headers["Authorization"] = "Bearer " + plain
+	res := req.Get()
+	as.Equal(404, res.Code)
+	as.Contains(res.Body.String(), `"status":404`)
+}
+
+func (as *ActionSuite) Test_APITokensResource_Create() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	as.Session.Set("current_user_id", u.ID)
+
+	// the token is shown in the response itself, not flashed through the session
+	res := as.HTML("/tokens").Post(map[string]interface{}{"Name": "script"})
+	as.Equal(200, res.Code)
+	as.Equal("no-store", res.Header().Get("Cache-Control"))
+	m := regexp.MustCompile(`value="([^"]+)" readonly`).FindStringSubmatch(res.Body.String())
+	as.Len(m, 2)
+
+	token := &models.APIToken{}
+	as.NoError(as.DB.Where("user_id = ?", u.ID).First(token))
+	as.Equal(token.TokenHash, models.HashAPIToken(m[1]))
+
+	req := as.JSON("/api/v1/me")
+	req.Headers["Authorization"] = "Bearer " + m[1]
+	as.Equal(200, req.Get().Code)
+}
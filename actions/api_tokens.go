@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/middleware/csrf"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// APITokensResource lets users manage their personal API tokens
+// from their profile page
+type APITokensResource struct {
+	buffalo.Resource
+}
+
+// Create adds a token for the current user and shows it to her, once.
+// This function is mapped to the path POST /tokens
+func (v APITokensResource) Create(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	token, plain, err := models.NewAPIToken(user.ID, c.Request().FormValue("Name"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// optional expiry, in days
+	if days, err := strconv.Atoi(c.Request().FormValue("ExpiresIn")); err == nil && days > 0 {
+		token.ExpiresAt = nulls.NewTime(time.Now().AddDate(0, 0, days))
+	}
+
+	verrs, err := tx.ValidateAndCreate(token)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		for _, msgs := range verrs.Errors {
+			for _, msg := range msgs {
+				c.Flash().Add("danger", msg)
+			}
+		}
+		return c.Redirect(302, "/users/%s", user.ID)
+	}
+
+	// the token is only ever in this response: flash messages live in the
+	// session cookie, and nothing but its hash is kept
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Set("token", token)
+	c.Set("plain_token", plain)
+	return c.Render(200, r.HTML("users/token_created.html"))
+}
+
+// Destroy revokes one of the current user's tokens.
+// This function is mapped to the path DELETE /tokens/{token_id}
+func (v APITokensResource) Destroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	token := &models.APIToken{}
+	if err := tx.Where("id = ? AND user_id = ?", c.Param("token_id"), user.ID).First(token); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := tx.Destroy(token); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "apitoken.destroyed.success"))
+	return c.Redirect(302, "/users/%s", user.ID)
+}
+
+// CSRFUnlessToken protects session authenticated requests against CSRF attacks.
+// Requests authenticated with an API token carry no ambient credentials
+// the browser could send on a user's behalf, so they skip the check.
+func CSRFUnlessToken(next buffalo.Handler) buffalo.Handler {
+	protected := csrf.New(next)
+	return func(c buffalo.Context) error {
+		if bearerToken(c) != "" {
+			return next(c)
+		}
+		return protected(c)
+	}
+}
@@ -1,13 +1,14 @@
 package actions
 
 import (
+	"log"
+
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/buffalo/middleware"
 	"github.com/gobuffalo/buffalo/middleware/ssl"
 	"github.com/gobuffalo/envy"
 	"github.com/unrolled/secure"
 
-	"github.com/gobuffalo/buffalo/middleware/csrf"
 	"github.com/gobuffalo/buffalo/middleware/i18n"
 	"github.com/gobuffalo/packr"
 	"github.com/markbates/goth/gothic"
@@ -27,8 +28,14 @@ var T *i18n.Translator
 // application.
 func App() *buffalo.App {
 	if app == nil {
+		// links would point nowhere, better not start at all
+		if appURLErr != nil {
+			log.Fatal(appURLErr)
+		}
+
 		app = buffalo.New(buffalo.Options{
 			Env:         ENV,
+			Host:        AppURL,
 			SessionName: "_kumano_session",
 		})
 		// Automatically redirect to SSL
@@ -42,8 +49,9 @@ func App() *buffalo.App {
 		}
 
 		// Protect against CSRF attacks. https://www.owasp.org/index.php/Cross-Site_Request_Forgery_(CSRF)
+		// Requests authenticated with an API token don't need it.
 		// Remove to disable this.
-		app.Use(csrf.New)
+		app.Use(CSRFUnlessToken)
 
 		// Wraps each request in a transaction.
 		//  c.Value("tx").(*pop.PopTransaction)
@@ -53,6 +61,9 @@ func App() *buffalo.App {
 		// setting the user in the session
 		app.Use(SetCurrentUser)
 
+		// unread notifications badge in the header
+		app.Use(SetUnreadNotifications)
+
 		// Setup and use translations:
 		var err error
 		if T, err = i18n.New(packr.NewBox("../locales"), "en-US"); err != nil {
@@ -60,8 +71,20 @@ func App() *buffalo.App {
 		}
 		app.Use(T.Middleware())
 
+		// background jobs, e.g. large data exports
+		if err := registerJobs(app.Worker); err != nil {
+			app.Stop(err)
+		}
+
 		//ROUTING
 		app.GET("/", HomeHandler)
+		app.GET("/search", SearchHandler)
+
+		// unsubscribe from emails, the signed link is the credential:
+		// GET only asks for confirmation, the POST of the mail client's one-click unsubscribe does it
+		app.GET("/unsubscribe/{user_id}/{list}/{token}", UnsubscribeConfirm)
+		app.POST("/unsubscribe/{user_id}/{list}/{token}", UnsubscribeHandler)
+		app.Middleware.Skip(CSRFUnlessToken, UnsubscribeHandler)
 
 		// authentication of users
 		auth := app.Group("/auth")
@@ -74,35 +97,130 @@ func App() *buffalo.App {
 		// texts routes
 		//
 		// single pages, not linked to text model directly
-		app.POST("/texts/{text_id}/star", StarHandler)
+		app.POST("/texts/{text_id}/star", LoginRequired(StarHandler))
+		app.DELETE("/texts/{text_id}/star", LoginRequired(UnstarHandler))
+		app.POST("/texts/{text_id}/flag", LoginRequired(FlagHandler))
 
 		// texts group routes
 		tr := &TextsResource{}
 		textsGroup := app.Group("/texts")
-		textsGroup.Use(LoginRequired)
-		textsGroup.Middleware.Skip(LoginRequired, tr.Show, tr.List)
+		textsGroup.Use(LoginRequired, TextOwnerRequired)
+		textsGroup.Middleware.Skip(LoginRequired, tr.Show, tr.List, RevisionsList)
+		textsGroup.Middleware.Skip(TextOwnerRequired, tr.Show, tr.List, tr.New, tr.Create, tr.ListDrafts, tr.ListUserTexts, RevisionsList, CommentCreate, CommentEdit, CommentUpdate, CommentDestroy, ImportNew, ImportPreview, ImportCreate)
 		textsGroup.GET("/", tr.List)
 		textsGroup.POST("/", tr.Create)
 		textsGroup.GET("/new", tr.New)
 		textsGroup.GET("/drafts", tr.ListDrafts)
 		textsGroup.GET("/user/{user_id}", tr.ListUserTexts)
+		textsGroup.GET("/import", ImportNew)
+		textsGroup.POST("/import", ImportPreview)
+		textsGroup.POST("/import/confirm", ImportCreate)
 		textsGroup.GET("/{text_id}", tr.Show)
 		textsGroup.GET("/{text_id}/edit", tr.Edit)
 		textsGroup.PUT("/{text_id}", tr.Update)
 		textsGroup.DELETE("/{text_id}", tr.Destroy)
+		textsGroup.GET("/{text_id}/revisions", RevisionsList)
+		textsGroup.POST("/{text_id}/revisions/{revision_id}/restore", RevisionRestore)
+		textsGroup.POST("/{text_id}/comments", CommentCreate)
+		// /comments/lock before /comments/{comment_id} so it isn't taken for a comment
+		textsGroup.POST("/{text_id}/comments/lock", CommentsLock)
+		textsGroup.DELETE("/{text_id}/comments/lock", CommentsUnlock)
+		textsGroup.GET("/{text_id}/comments/{comment_id}/edit", CommentEdit)
+		textsGroup.PUT("/{text_id}/comments/{comment_id}", CommentUpdate)
+		textsGroup.DELETE("/{text_id}/comments/{comment_id}", CommentDestroy)
+
+		// tags, /tags/suggest before /tags/{slug} so it isn't taken for a tag
+		app.GET("/tags", TagsIndex)
+		app.GET("/tags/suggest", TagsSuggest)
+		app.GET("/tags/{slug}", TagShow)
+
+		// feeds, the format is atom or rss
+		app.GET("/feeds/texts.{format}", TextsFeed)
+		app.GET("/feeds/users/{user_id}.{format}", UserFeed)
+		app.GET("/feeds/tags/{slug}.{format}", TagFeed)
+		app.GET("/feeds/starred/{user_id}/{token}.{format}", StarredFeed)
 
 		// users routes
+		// single pages, not linked to user model directly
+		app.POST("/users/{user_id}/follow", LoginRequired(FollowHandler))
+		app.DELETE("/users/{user_id}/follow", LoginRequired(UnfollowHandler))
+
 		ur := &UsersResource{}
 		usersGroup := app.Group("/users")
-		usersGroup.Use(LoginRequired)
-		usersGroup.Middleware.Skip(LoginRequired, ur.Show)
+		usersGroup.Use(LoginRequired, UserOwnerRequired)
+		usersGroup.Middleware.Skip(LoginRequired, ur.Show, FollowersList, FollowingList, Leaderboard)
+		usersGroup.Middleware.Skip(UserOwnerRequired, ur.List, ur.New, ur.Show, ur.Create, FollowersList, FollowingList, Leaderboard)
 		usersGroup.GET("/", ur.List)                // GET /users => ur.List
+		usersGroup.GET("/leaderboard", Leaderboard) // GET /users/leaderboard => Leaderboard, before /{user_id}
 		usersGroup.GET("/new", ur.New)              // GET /users/new => ur.New
 		usersGroup.GET("/{user_id}", ur.Show)       // GET /users/{user_id} => ur.Show
 		usersGroup.GET("/{user_id}/edit", ur.Edit)  // GET /users/{user_id}/edit => ur.Edit
 		usersGroup.POST("/", ur.Create)             // POST /users => ur.Create
 		usersGroup.PUT("/{user_id}", ur.Update)     // PUT /users/{user_id} => ur.Update
 		usersGroup.DELETE("/{user_id}", ur.Destroy) //  DELETE /users/{user_id} => ur.Destroy
+		usersGroup.GET("/{user_id}/delete", DeletionNew)
+		usersGroup.DELETE("/{user_id}/deletion", DeletionCancel)
+		usersGroup.GET("/{user_id}/followers", FollowersList)
+		usersGroup.GET("/{user_id}/following", FollowingList)
+
+		// JSON API, authenticated with bearer tokens rather than session cookies
+		atr := &APITextsResource{}
+		aur := &APIUsersResource{}
+		apiGroup := app.Group("/api/v1")
+		apiGroup.Use(APIAuthenticate)
+		apiGroup.GET("/texts", atr.List)
+		apiGroup.POST("/texts", atr.Create)
+		apiGroup.GET("/drafts", atr.Drafts)
+		apiGroup.GET("/texts/{text_id}", atr.Show)
+		apiGroup.PUT("/texts/{text_id}", atr.Update)
+		apiGroup.DELETE("/texts/{text_id}", atr.Destroy)
+		apiGroup.GET("/texts/{text_id}/stars", atr.Stars)
+		apiGroup.POST("/texts/{text_id}/star", atr.Star)
+		apiGroup.DELETE("/texts/{text_id}/star", atr.Unstar)
+		apiGroup.GET("/me", aur.Me)
+		apiGroup.GET("/users", aur.List)
+		apiGroup.GET("/users/{user_id}", aur.Show)
+		apiGroup.GET("/users/{user_id}/texts", aur.Texts)
+		apiGroup.GET("/users/{user_id}/starred", aur.Starred)
+
+		// personal API tokens
+		atkr := &APITokensResource{}
+		tokensGroup := app.Group("/tokens")
+		tokensGroup.Use(LoginRequired)
+		tokensGroup.POST("/", atkr.Create)
+		tokensGroup.DELETE("/{token_id}", atkr.Destroy)
+
+		// notifications of the current user
+		notificationsGroup := app.Group("/notifications")
+		notificationsGroup.Use(LoginRequired)
+		notificationsGroup.GET("/", NotificationsList)
+		notificationsGroup.PUT("/read", NotificationsReadAll)
+		notificationsGroup.PUT("/{notification_id}/read", NotificationRead)
+
+		// invitations sent by the current user
+		invitationsGroup := app.Group("/invitations")
+		invitationsGroup.Use(LoginRequired)
+		invitationsGroup.GET("/", InvitationsList)
+		invitationsGroup.PUT("/{invitation_id}/resend", InvitationResend)
+		invitationsGroup.DELETE("/{invitation_id}", InvitationRevoke)
+
+		// data exports of the current user
+		exportsGroup := app.Group("/exports")
+		exportsGroup.Use(LoginRequired)
+		exportsGroup.GET("/", ExportsList)
+		exportsGroup.POST("/", ExportCreate)
+		exportsGroup.GET("/{export_id}/download", ExportDownload)
+
+		// admin routes
+		adminGroup := app.Group("/admin")
+		adminGroup.Use(LoginRequired, AdminRequired)
+		adminGroup.GET("/flags", FlagsQueue)
+		adminGroup.PUT("/flags/{flag_id}/dismiss", FlagDismiss)
+		adminGroup.PUT("/flags/{flag_id}/uphold", FlagUphold)
+		adminGroup.PUT("/users/{user_id}/sponsorships", SponsorshipsGrant)
+		adminGroup.GET("/tags", TagsAdmin)
+		adminGroup.PUT("/tags/{tag_id}", TagRename)
+		adminGroup.POST("/tags/{tag_id}/merge", TagMerge)
 
 		app.ServeFiles("/", assetsBox) // serve files from the public directory
 	}
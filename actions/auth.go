@@ -9,6 +9,7 @@ import (
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/github"
@@ -17,12 +18,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+// providers are the authentication providers users can sign up with
+var providers = []string{"github", "twitter"}
+
 func init() {
 	gothic.Store = App().SessionStore
 
 	goth.UseProviders(
-		twitter.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), fmt.Sprintf("%s%s", App().Host, "/auth/twitter/callback")),
-		github.New(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), fmt.Sprintf("%s%s", App().Host, "/auth/github/callback")),
+		twitter.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), AbsoluteURL("/auth/twitter/callback")),
+		github.New(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), AbsoluteURL("/auth/github/callback")),
 	)
 }
 
@@ -30,11 +34,22 @@ func init() {
 func AuthCallback(c buffalo.Context) error {
 	gothUser, err := gothic.CompleteUserAuth(c.Response(), c.Request())
 	if err != nil {
+		// a failed login doesn't confirm a deletion, nor leave it to a later one
+		forgetDeletion(c)
+		if err := c.Session().Save(); err != nil {
+			return errors.WithStack(err)
+		}
 		return c.Error(401, err)
 	}
 
-	// check user already exists or not
 	tx := c.Value("tx").(*pop.Connection)
+
+	// logging in again to confirm an account deletion, see UsersResource.Destroy
+	if _, ok := c.Session().Get(sessionDeletionUser).(string); ok {
+		return confirmDeletion(c, tx, gothUser)
+	}
+
+	// check user already exists or not
 	q := tx.Where("provider = ? and provider_id = ?", gothUser.Provider, gothUser.UserID)
 	exists, err := q.Exists("users")
 	if err != nil {
@@ -52,22 +67,10 @@ func AuthCallback(c buffalo.Context) error {
 
 		// user logged in
 		// minus 1 point for days not logged in + 1 for logging in today
-		diff := int(time.Since(u.LastLoggedAt) / time.Minute)
-		u.Score += -(diff / 1440) + models.PointsLogsIn
-		u.LastLoggedAt = time.Now()
-
-		verrs, err := tx.ValidateAndUpdate(u)
-		if err != nil {
+		if err := models.CreditLogIn(tx, u, time.Now()); err != nil {
 			return errors.WithStack(err)
 		}
 
-		if verrs.HasAny() {
-			// Make the errors available inside the html template
-			c.Set("errors", verrs)
-			fmt.Printf("\nVerrs: %v\n", verrs)
-			return c.Redirect(302, "/")
-		}
-
 		// set session user to logged in user and redirect to home
 
 		// FIXME: either user current_user_id or current_user
@@ -110,8 +113,6 @@ func AuthCallback(c buffalo.Context) error {
 			c.Flash().Add("success", mssg)
 		}
 
-		// new user gets points on creation
-		u.Score += models.PointsCreatesAccount
 		u.SignedUpAt = time.Now()
 		u.LastLoggedAt = time.Now()
 
@@ -126,6 +127,23 @@ func AuthCallback(c buffalo.Context) error {
 			return errors.WithStack(err)
 		}
 
+		// new user gets points on creation
+		if err := models.CreditSignUp(tx, u); err != nil {
+			return errors.WithStack(err)
+		}
+
+		// let the sponsor know her invitation was accepted
+		if u.SponsorID != uuid.Nil {
+			err = models.Notify(tx, &models.Notification{
+				UserID:  u.SponsorID,
+				ActorID: u.ID,
+				Kind:    models.NotificationSignedUp,
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return c.Redirect(302, "/")
 
 	}
@@ -142,7 +160,8 @@ func AuthDestroy(c buffalo.Context) error {
 	return c.Redirect(302, "/")
 }
 
-// InvitationRedeem gives access to the
+// InvitationRedeem gives access to the signup page to invited people,
+// as long as their invitation hasn't expired
 func InvitationRedeem(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -160,6 +179,15 @@ func InvitationRedeem(c buffalo.Context) error {
 		return c.Render(403, r.HTML("/"))
 	}
 
+	// expired invitations are revoked on the spot, giving the sponsorship back
+	if user.InvitationExpired() {
+		if err := models.RevokeInvitation(tx, &user); err != nil {
+			return errors.WithStack(err)
+		}
+		c.Flash().Add("danger", T.Translate(c, "auth.invitation.expired"))
+		return c.Redirect(302, "/")
+	}
+
 	// set current user in session
 	c.Session().Set("current_user_id", user.ID)
 	if err := c.Session().Save(); err != nil {
@@ -0,0 +1,182 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// CommentCreate adds a comment, or a reply with the form field ParentID, to a text.
+// This function is mapped to the path POST /texts/{text_id}/comments
+func CommentCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return c.Error(404, err)
+	}
+	// drafts and hidden texts can't be seen, let alone commented on
+	if (text.Draft || text.Hidden) && !user.CanManage(text.AuthorID) {
+		return c.Error(404, errors.New("text not found"))
+	}
+	if !user.Can(models.PrivilegeComment) {
+		c.Flash().Add("danger", fmt.Sprintf(T.Translate(c, "privilege.comment.locked"), user.PointsTo(models.PrivilegeComment)))
+		return c.Redirect(302, "/texts/%s", text.ID)
+	}
+
+	form := commentForm{}
+	if err := c.Bind(&form); err != nil {
+		return errors.WithStack(err)
+	}
+	comment := &models.Comment{
+		TextID:   text.ID,
+		AuthorID: user.ID,
+	}
+	form.apply(comment)
+	if parentID, err := uuid.FromString(c.Request().FormValue("ParentID")); err == nil {
+		comment.ParentID = nulls.NewUUID(parentID)
+	}
+
+	verrs, err := models.PostComment(tx, comment)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		for _, msgs := range verrs.Errors {
+			for _, msg := range msgs {
+				c.Flash().Add("danger", msg)
+			}
+		}
+		return c.Redirect(302, "/texts/%s", text.ID)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "comment.created.success"))
+	return c.Redirect(302, "/texts/%s#comment-%s", text.ID, comment.ID)
+}
+
+// CommentEdit renders the form to edit a comment.
+// This function is mapped to the path GET /texts/{text_id}/comments/{comment_id}/edit
+func CommentEdit(c buffalo.Context) error {
+	_, comment, err := findOwnComment(c)
+	if err != nil {
+		return err
+	}
+
+	c.Set("comment", comment)
+	return c.Render(200, r.HTML("texts/comment_edit.html"))
+}
+
+// CommentUpdate changes the content of a comment.
+// This function is mapped to the path PUT /texts/{text_id}/comments/{comment_id}
+func CommentUpdate(c buffalo.Context) error {
+	tx, comment, err := findOwnComment(c)
+	if err != nil {
+		return err
+	}
+
+	form := commentForm{}
+	if err := c.Bind(&form); err != nil {
+		return errors.WithStack(err)
+	}
+	form.apply(comment)
+
+	verrs, err := tx.ValidateAndUpdate(comment)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		c.Set("comment", comment)
+		c.Set("errors", verrs)
+		return c.Render(422, r.HTML("texts/comment_edit.html"))
+	}
+
+	c.Flash().Add("success", T.Translate(c, "comment.updated.success"))
+	return c.Redirect(302, "/texts/%s#comment-%s", comment.TextID, comment.ID)
+}
+
+// CommentDestroy deletes a comment.
+// This function is mapped to the path DELETE /texts/{text_id}/comments/{comment_id}
+func CommentDestroy(c buffalo.Context) error {
+	tx, comment, err := findOwnComment(c)
+	if err != nil {
+		return err
+	}
+
+	if err := models.DeleteComment(tx, comment); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "comment.destroyed.success"))
+	return c.Redirect(302, "/texts/%s", comment.TextID)
+}
+
+// CommentsLock closes a text to new comments.
+// This function is mapped to the path POST /texts/{text_id}/comments/lock
+func CommentsLock(c buffalo.Context) error {
+	return setCommentsLocked(c, true)
+}
+
+// CommentsUnlock opens a text to new comments again.
+// This function is mapped to the path DELETE /texts/{text_id}/comments/lock
+func CommentsUnlock(c buffalo.Context) error {
+	return setCommentsLocked(c, false)
+}
+
+// setCommentsLocked locks or unlocks the comments on the text,
+// TextOwnerRequired already checked the current user may do so
+func setCommentsLocked(c buffalo.Context, locked bool) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	err := tx.RawQuery("UPDATE texts SET comments_locked = ? WHERE id = ?", locked, text.ID).Exec()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if locked {
+		c.Flash().Add("success", T.Translate(c, "comment.locked.success"))
+	} else {
+		c.Flash().Add("success", T.Translate(c, "comment.unlocked.success"))
+	}
+	return c.Redirect(302, "/texts/%s", text.ID)
+}
+
+// findOwnComment retrieves the comment from the route params text_id and comment_id,
+// only its author or an admin may change it
+func findOwnComment(c buffalo.Context) (*pop.Connection, *models.Comment, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	comment := &models.Comment{}
+	err := tx.Where("id = ? AND text_id = ? AND deleted = ?", c.Param("comment_id"), c.Param("text_id"), false).First(comment)
+	if err != nil {
+		return nil, nil, c.Error(404, err)
+	}
+
+	u := c.Value("current_user").(*models.User)
+	if !u.CanManage(comment.AuthorID) {
+		return nil, nil, c.Error(403, errors.New("only the owner or an admin can do that"))
+	}
+	return tx, comment, nil
+}
@@ -0,0 +1,63 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_Comments() {
+	author := &models.User{}
+	commenter := &models.User{}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(commenter))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+
+	as.Session.Set("current_user_id", commenter.ID)
+	res := as.HTML("/texts/%s/comments", text.ID).Post(map[string]interface{}{
+		"Content": "Lovely *walk*",
+	})
+	as.Equal(302, res.Code)
+	comment := &models.Comment{}
+	as.NoError(as.DB.Where("text_id = ?", text.ID).First(comment))
+	as.Equal(commenter.ID, comment.AuthorID)
+
+	res = as.HTML("/texts/%s", text.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "<em>walk</em>")
+
+	// the text's author can't edit someone else's comment, but can lock her text
+	as.Session.Set("current_user_id", author.ID)
+	res = as.HTML("/texts/%s/comments/%s", text.ID, comment.ID).Put(map[string]interface{}{"Content": "Forged"})
+	as.Equal(403, res.Code)
+
+	res = as.HTML("/texts/%s/comments/lock", text.ID).Post(map[string]interface{}{})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(text))
+	as.True(text.CommentsLocked)
+
+	as.Session.Set("current_user_id", commenter.ID)
+	// only the text's author may unlock it
+	res = as.HTML("/texts/%s/comments/lock", text.ID).Delete()
+	as.Equal(403, res.Code)
+
+	res = as.HTML("/texts/%s/comments", text.ID).Post(map[string]interface{}{"Content": "Too late"})
+	as.Equal(302, res.Code)
+	count, err := as.DB.Where("text_id = ?", text.ID).Count("comments")
+	as.NoError(err)
+	as.Equal(1, count)
+
+	res = as.HTML("/texts/%s/comments/%s", text.ID, comment.ID).Put(map[string]interface{}{"Content": "Edited"})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(comment))
+	as.Equal("Edited", comment.Content)
+
+	res = as.HTML("/texts/%s/comments/%s", text.ID, comment.ID).Delete()
+	as.Equal(302, res.Code)
+
+	// the author unlocks them
+	as.Session.Set("current_user_id", author.ID)
+	res = as.HTML("/texts/%s/comments/lock", text.ID).Delete()
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(text))
+	as.False(text.CommentsLocked)
+}
@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/goth"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// Deleting an account is confirmed by logging in again with its provider:
+// UsersResource.Destroy remembers what to delete under these session keys,
+// and AuthCallback schedules it once the provider vouched for the user,
+// provided it happens within deletionConfirmTTL.
+const (
+	sessionDeletionUser = "deletion_user_id"
+	sessionDeletionMode = "deletion_mode"
+	sessionDeletionAt   = "deletion_requested_at"
+	deletionConfirmTTL  = 5 * time.Minute
+)
+
+// forgetDeletion drops the deletion waiting for confirmation, if any
+func forgetDeletion(c buffalo.Context) {
+	c.Session().Delete(sessionDeletionUser)
+	c.Session().Delete(sessionDeletionMode)
+	c.Session().Delete(sessionDeletionAt)
+}
+
+// DeletionNew renders the page to delete an account, where the user chooses
+// what happens to her texts and comments.
+// This function is mapped to the path GET /users/{user_id}/delete
+func DeletionNew(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	c.Set("user", user)
+	c.Set("grace_days", int(models.DeletionGrace.Hours()/24))
+	return c.Render(200, r.HTML("users/delete.html"))
+}
+
+// DeletionCancel keeps an account whose deletion is scheduled.
+// This function is mapped to the path DELETE /users/{user_id}/deletion
+func DeletionCancel(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := models.CancelDeletion(tx, user); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "user.deletion.cancelled"))
+	return c.Redirect(302, "/users/%s", user.ID)
+}
+
+// confirmDeletion schedules the deletion asked for in UsersResource.Destroy,
+// provided the user who just logged in with her provider is the one who asked,
+// a few minutes ago at most
+func confirmDeletion(c buffalo.Context, tx *pop.Connection, gothUser goth.User) error {
+	id, _ := c.Session().Get(sessionDeletionUser).(string)
+	mode, _ := c.Session().Get(sessionDeletionMode).(string)
+	at, _ := c.Session().Get(sessionDeletionAt).(int64)
+	forgetDeletion(c)
+
+	if time.Since(time.Unix(at, 0)) > deletionConfirmTTL {
+		c.Flash().Add("danger", T.Translate(c, "user.deletion.reauth.expired"))
+		return c.Redirect(302, "/")
+	}
+
+	cu, ok := c.Value("current_user").(*models.User)
+	if !ok || cu.Provider.String != gothUser.Provider || cu.ProviderID.String != gothUser.UserID {
+		c.Flash().Add("danger", T.Translate(c, "user.deletion.reauth.failure"))
+		return c.Redirect(302, "/")
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, id); err != nil {
+		return c.Error(404, err)
+	}
+	if !cu.CanManage(user.ID) {
+		return c.Error(403, errors.New("only the owner or an admin can do that"))
+	}
+
+	if err := models.ScheduleDeletion(tx, user, mode, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	due := user.DeletionDueAt.Time.Format("Jan 2, 2006")
+	c.Flash().Add("warning", fmt.Sprintf(T.Translate(c, "user.deletion.scheduled"), due))
+	return c.Redirect(302, "/users/%s", user.ID)
+}
@@ -0,0 +1,216 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/mailers"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// the mailing lists a user can unsubscribe from in one click
+const (
+	listDigest        = "digest"
+	listNotifications = "notifications"
+)
+
+// notificationSubjects are the subjects of single notification emails
+var notificationSubjects = map[string]string{
+	models.NotificationStarred:   "Your text got a star on Kumano",
+	models.NotificationSignedUp:  "Your invitation to Kumano was accepted",
+	models.NotificationMentioned: "You were mentioned on Kumano",
+}
+
+// UnsubscribeURL is the one-click link to stop receiving the emails of a list,
+// it is signed so it can't be forged for another user
+func UnsubscribeURL(userID uuid.UUID, list string) (string, error) {
+	token, err := unsubscribeToken(userID, list)
+	if err != nil {
+		return "", err
+	}
+	return AbsoluteURL("/unsubscribe/%s/%s/%s", userID, list, token), nil
+}
+
+// unsubscribeToken signs the user id and list with the app secret
+func unsubscribeToken(userID uuid.UUID, list string) (string, error) {
+	return signUserToken(userID, list)
+}
+
+// signUserToken signs the user id and what the link is for with the app secret,
+// for links that act on behalf of a user without her session
+func signUserToken(userID uuid.UUID, purpose string) (string, error) {
+	secret := envy.Get("SESSION_SECRET", "")
+	if secret == "" {
+		if ENV == "production" {
+			return "", errors.New("SESSION_SECRET is required to sign links")
+		}
+		// same fallback as buffalo's session store outside production
+		secret = "buffalo-secret"
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID.String() + ":" + purpose))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// UnsubscribeConfirm asks the user to confirm she wants to stop receiving a list
+// of emails, from the link in the email. Opening the link changes nothing, as mail
+// scanners and link prefetchers follow them: the form posts to UnsubscribeHandler.
+// This function is mapped to the path GET /unsubscribe/{user_id}/{list}/{token}
+func UnsubscribeConfirm(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, list, err := unsubscribeLink(c, tx)
+	if err != nil {
+		return err
+	}
+
+	c.Set("list", list)
+	c.Set("user", user)
+	// the form posts back to the same signed link
+	c.Set("unsubscribe_path", c.Request().URL.Path)
+	return c.Render(200, r.HTML("users/unsubscribe.html"))
+}
+
+// UnsubscribeHandler turns off a list of emails for a user, from the confirmation
+// page or from the mail client's one-click unsubscribe (RFC 8058).
+// This function is mapped to the path POST /unsubscribe/{user_id}/{list}/{token}
+func UnsubscribeHandler(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user, list, err := unsubscribeLink(c, tx)
+	if err != nil {
+		return err
+	}
+
+	switch list {
+	case listDigest:
+		err = tx.RawQuery("UPDATE users SET digest_frequency = ? WHERE id = ?", models.DigestNever, user.ID).Exec()
+	case listNotifications:
+		err = tx.RawQuery("UPDATE users SET email_notifications = ? WHERE id = ?", false, user.ID).Exec()
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("list", list)
+	c.Set("user", user)
+	return c.Render(200, r.HTML("users/unsubscribed.html"))
+}
+
+// unsubscribeLink checks the signature of an unsubscribe link and returns
+// its user and list, answering 404 for forged links and unknown lists
+func unsubscribeLink(c buffalo.Context, tx *pop.Connection) (*models.User, string, error) {
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return nil, "", c.Error(404, err)
+	}
+
+	list := c.Param("list")
+	expected, err := unsubscribeToken(user.ID, list)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	if !hmac.Equal([]byte(expected), []byte(c.Param("token"))) {
+		return nil, "", c.Error(404, errors.New("invalid unsubscribe link"))
+	}
+	if list != listDigest && list != listNotifications {
+		return nil, "", c.Error(404, errors.New("unknown mailing list"))
+	}
+	return user, list, nil
+}
+
+func init() {
+	models.Deliver = sendNotificationEmail
+}
+
+// sendNotificationEmail emails the notification to its recipient if she asked for it.
+// Failing to send an email doesn't fail what triggered the notification.
+func sendNotificationEmail(tx *pop.Connection, n *models.Notification) error {
+	user := &models.User{}
+	if err := tx.Find(user, n.UserID); err != nil {
+		return errors.WithStack(err)
+	}
+	if !user.EmailNotifications || user.Email.String == "" {
+		return nil
+	}
+
+	ns := models.Notifications{*n}
+	if err := models.LoadNotificationDetails(tx, ns); err != nil {
+		return err
+	}
+
+	unsubscribe, err := UnsubscribeURL(user.ID, listNotifications)
+	if err != nil {
+		return err
+	}
+	if err := mailers.SendNotification(*user, notificationSubjects[n.Kind], &ns[0], AbsoluteURL(""), unsubscribe); err != nil {
+		log.Printf("sending notification %s to %s: %v", n.ID, user.ID, err)
+	}
+	return nil
+}
+
+// SendDigests emails their digest to the users who get them at the given frequency
+// ("daily" or "weekly") and are due for one. Users with nothing new get no email.
+// Each user is handled in her own transaction, so that one failing doesn't have
+// the others mailed again next time: it is logged and tried again on the next run.
+// Returns the number of digests sent. Run by the digests:send grift.
+func SendDigests(db *pop.Connection, frequency string, now time.Time) (int, error) {
+	users, err := models.DueForDigest(db, frequency, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, u := range users {
+		mailed := false
+		err := db.Transaction(func(tx *pop.Connection) error {
+			var err error
+			mailed, err = sendDigest(tx, u, now)
+			return err
+		})
+		if err != nil {
+			log.Printf("sending digest to %s: %v", u.ID, err)
+			continue
+		}
+		if mailed {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest emails her digest to the user, if there is anything new,
+// and records she got it. It tells if a mail was sent.
+func sendDigest(tx *pop.Connection, u models.User, now time.Time) (bool, error) {
+	digest, err := models.BuildDigest(tx, u, now)
+	if err != nil {
+		return false, err
+	}
+
+	if !digest.Empty() {
+		unsubscribe, err := UnsubscribeURL(u.ID, listDigest)
+		if err != nil {
+			return false, err
+		}
+		if err := mailers.SendDigest(digest, AbsoluteURL(""), unsubscribe); err != nil {
+			return false, err
+		}
+	}
+	return !digest.Empty(), models.DigestSent(tx, u, now)
+}
@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_SendDigests() {
+	reader := &models.User{Email: nulls.NewString("reader@example.com"), ProviderID: nulls.NewString("1"), DigestFrequency: models.DigestDaily}
+	quiet := &models.User{Email: nulls.NewString("quiet@example.com"), ProviderID: nulls.NewString("2"), DigestFrequency: models.DigestDaily}
+	author := &models.User{Name: nulls.NewString("Author")}
+	for _, u := range []*models.User{reader, quiet, author} {
+		as.NoError(as.DB.Create(u))
+	}
+	_, err := models.FollowUser(as.DB, reader.ID, author.ID)
+	as.NoError(err)
+	as.NoError(as.DB.Create(&models.Text{Title: "Kumano Kodo", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now().Add(-time.Hour))}))
+
+	sent, err := SendDigests(as.DB, models.DigestDaily, time.Now())
+	as.NoError(err)
+	as.Equal(1, sent)
+	as.Len(as.Mails(), 1)
+	m := as.Mails()[0]
+	as.Equal([]string{"reader@example.com"}, m.To)
+	as.Contains(m.Bodies[0].Content, "Kumano Kodo")
+	as.Contains(m.Headers["List-Unsubscribe"], "/unsubscribe/"+reader.ID.String()+"/digest/")
+
+	// not due again until tomorrow
+	sent, err = SendDigests(as.DB, models.DigestDaily, time.Now())
+	as.NoError(err)
+	as.Equal(0, sent)
+}
+
+func (as *ActionSuite) Test_UnsubscribeHandler() {
+	user := &models.User{EmailNotifications: true}
+	as.NoError(as.DB.Create(user))
+	as.Equal(models.DigestWeekly, user.DigestFrequency)
+
+	// forged links don't work
+	res := as.HTML("/unsubscribe/%s/digest/%s", user.ID, "forged").Get()
+	as.Equal(404, res.Code)
+
+	token, err := unsubscribeToken(user.ID, listDigest)
+	as.NoError(err)
+	// following the link only asks for confirmation, as scanners follow links too
+	res = as.HTML("/unsubscribe/%s/digest/%s", user.ID, token).Get()
+	as.Equal(200, res.Code)
+	as.NoError(as.DB.Reload(user))
+	as.Equal(models.DigestWeekly, user.DigestFrequency)
+
+	res = as.HTML("/unsubscribe/%s/digest/%s", user.ID, token).Post(map[string]interface{}{})
+	as.Equal(200, res.Code)
+	as.NoError(as.DB.Reload(user))
+	as.Equal(models.DigestNever, user.DigestFrequency)
+	as.True(user.EmailNotifications)
+
+	// one-click from the mail client
+	token, err = unsubscribeToken(user.ID, listNotifications)
+	as.NoError(err)
+	res = as.HTML("/unsubscribe/%s/notifications/%s", user.ID, token).Post(map[string]interface{}{"List-Unsubscribe": "One-Click"})
+	as.Equal(200, res.Code)
+	as.NoError(as.DB.Reload(user))
+	as.False(user.EmailNotifications)
+}
+
+func (as *ActionSuite) Test_NotificationEmail() {
+	author := &models.User{Email: nulls.NewString("author@example.com"), EmailNotifications: true}
+	fan := &models.User{}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(fan))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+	as.NoError(as.DB.Create(text))
+
+	as.Session.Set("current_user_id", fan.ID)
+	res := as.JSON("/texts/%s/star", text.ID).Post(nil)
+	as.Equal(200, res.Code)
+
+	as.Len(as.Mails(), 1)
+	as.Equal([]string{"author@example.com"}, as.Mails()[0].To)
+	as.Contains(as.Mails()[0].Bodies[0].Content, "starred your text")
+}
@@ -0,0 +1,167 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/mailers"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// jobBuildExport builds a large export in the background, see ExportCreate
+const jobBuildExport = "export:build"
+
+// jobs runs the background jobs, it is the app's worker
+var jobs worker.Worker
+
+// registerJobs registers the background jobs' handlers with the app's worker
+func registerJobs(wk worker.Worker) error {
+	jobs = wk
+	return jobs.Register(jobBuildExport, buildExportJob)
+}
+
+// ExportsList shows the current user's data exports, to download or request one.
+// This function is mapped to the path GET /exports
+func ExportsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	exports := models.Exports{}
+	if err := tx.Where("user_id = ?", user.ID).Order("created_at desc").All(&exports); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("exports", exports)
+	return c.Render(200, r.HTML("exports/index.html"))
+}
+
+// ExportCreate starts a new export of the current user's data. Small exports are
+// built right away, large ones by the worker which emails her once it's ready.
+// This function is mapped to the path POST /exports
+func ExportCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	pending, err := models.HasPendingExport(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if pending {
+		c.Flash().Add("info", T.Translate(c, "export.pending"))
+		return c.Redirect(302, "/exports")
+	}
+
+	large, err := models.ExportIsLarge(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	export := &models.Export{UserID: user.ID, Status: models.ExportPending}
+	if !large {
+		if err := tx.Create(export); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := models.BuildExport(tx, export); err != nil {
+			log.Printf("building export %s: %v", export.ID, err)
+			c.Flash().Add("danger", T.Translate(c, "export.failed"))
+			return c.Redirect(302, "/exports")
+		}
+		c.Flash().Add("success", T.Translate(c, "export.ready"))
+		return c.Redirect(302, "/exports")
+	}
+
+	// the worker doesn't see the request's transaction,
+	// so the export has to be committed before it is queued
+	if err := models.DB.Create(export); err != nil {
+		return errors.WithStack(err)
+	}
+	err = jobs.Perform(worker.Job{
+		Queue:   "default",
+		Handler: jobBuildExport,
+		Args:    worker.Args{"export_id": export.ID.String()},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "export.queued"))
+	return c.Redirect(302, "/exports")
+}
+
+// ExportDownload sends the archive of one of the current user's exports,
+// until it expires.
+// This function is mapped to the path GET /exports/{export_id}/download
+func ExportDownload(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	// someone else's export doesn't exist, as far as she's concerned
+	export := &models.Export{}
+	if err := tx.Where("id = ? AND user_id = ?", c.Param("export_id"), user.ID).First(export); err != nil {
+		return c.Error(404, err)
+	}
+	if !export.Downloadable() {
+		c.Flash().Add("warning", T.Translate(c, "export.expired"))
+		return c.Redirect(302, "/exports")
+	}
+
+	f, err := os.Open(export.Path())
+	if err != nil {
+		return c.Error(404, err)
+	}
+	defer f.Close()
+
+	return c.Render(200, r.Download(c, export.Filename(), f))
+}
+
+// buildExportJob builds the export given by "export_id" and emails its owner
+// the link to download it
+func buildExportJob(args worker.Args) error {
+	id, err := uuid.FromString(fmt.Sprint(args["export_id"]))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return models.DB.Transaction(func(tx *pop.Connection) error {
+		export := &models.Export{}
+		if err := tx.Find(export, id); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := models.BuildExport(tx, export); err != nil {
+			// keep the export marked failed for her to see
+			log.Printf("building export %s: %v", export.ID, err)
+			return nil
+		}
+
+		user := &models.User{}
+		if err := tx.Find(user, export.UserID); err != nil {
+			return errors.WithStack(err)
+		}
+		if user.Email.String == "" {
+			return nil
+		}
+		url := AbsoluteURL("/exports/%s/download", export.ID)
+		if err := mailers.SendExportReady(*user, url, export.ExpiresAt.Time); err != nil {
+			log.Printf("sending export %s to %s: %v", export.ID, user.ID, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_ExportCreate_Download() {
+	dir, err := ioutil.TempDir("", "exports")
+	as.NoError(err)
+	defer os.RemoveAll(dir)
+	defer func(d string) { models.ExportDir = d }(models.ExportDir)
+	models.ExportDir = dir
+
+	u := &models.User{}
+	stranger := &models.User{}
+	as.NoError(as.DB.Create(u))
+	as.NoError(as.DB.Create(stranger))
+	as.Session.Set("current_user_id", u.ID)
+
+	// a small export is built right away
+	res := as.HTML("/exports").Post(map[string]interface{}{})
+	as.Equal(302, res.Code)
+	export := &models.Export{}
+	as.NoError(as.DB.Where("user_id = ?", u.ID).First(export))
+	as.Equal(models.ExportReady, export.Status)
+
+	res = as.HTML("/exports").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Download")
+
+	res = as.HTML("/exports/%s/download", export.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Header().Get("Content-Disposition"), export.Filename())
+
+	// nobody else can download it
+	as.Session.Set("current_user_id", stranger.ID)
+	res = as.HTML("/exports/%s/download", export.ID).Get()
+	as.Equal(404, res.Code)
+}
@@ -0,0 +1,404 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/gobuffalo/plush"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// the feed formats, given as the extension of the feed path
+const (
+	feedAtom = "atom"
+	feedRSS  = "rss"
+)
+
+// feedSize is how many of the latest texts a feed lists
+const feedSize = 20
+
+// what the personal starred feed links are signed for, see signUserToken
+const starredFeedPurpose = "starred-feed"
+
+// feed is what a feed handler selects, its texts are the published ones matching Where
+type feed struct {
+	Title    string
+	Path     string // without the format extension
+	HTMLPath string // the page showing the same texts
+	Where    string
+	Args     []interface{}
+}
+
+// TextsFeed is the feed of every published text.
+// This function is mapped to the path GET /feeds/texts.{format}
+func TextsFeed(c buffalo.Context) error {
+	return renderFeed(c, feed{
+		Title:    T.Translate(c, "app_name"),
+		Path:     "/feeds/texts",
+		HTMLPath: "/texts",
+	})
+}
+
+// UserFeed is the feed of the texts an author published.
+// This function is mapped to the path GET /feeds/users/{user_id}.{format}
+func UserFeed(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	return renderFeed(c, feed{
+		Title:    fmt.Sprintf("%s - @%s", T.Translate(c, "app_name"), user.Nickname.String),
+		Path:     fmt.Sprintf("/feeds/users/%s", user.ID),
+		HTMLPath: fmt.Sprintf("/texts/user/%s", user.ID),
+		Where:    "author_id = ?",
+		Args:     []interface{}{user.ID},
+	})
+}
+
+// TagFeed is the feed of the texts with a tag.
+// This function is mapped to the path GET /feeds/tags/{slug}.{format}
+func TagFeed(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tag := &models.Tag{}
+	if err := tx.Where("slug = ?", c.Param("slug")).First(tag); err != nil {
+		return c.Error(404, err)
+	}
+
+	return renderFeed(c, feed{
+		Title:    fmt.Sprintf("%s - %s", T.Translate(c, "app_name"), tag.Name),
+		Path:     fmt.Sprintf("/feeds/tags/%s", tag.Slug),
+		HTMLPath: fmt.Sprintf("/tags/%s", tag.Slug),
+		Where:    "id IN (SELECT text_id FROM text_tags WHERE tag_id = ?)",
+		Args:     []interface{}{tag.ID},
+	})
+}
+
+// StarredFeed is the personal feed of the texts a user starred. Feed readers don't
+// have her session, so the link is signed instead, see StarredFeedURL.
+// This function is mapped to the path GET /feeds/starred/{user_id}/{token}.{format}
+func StarredFeed(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+	expected, err := signUserToken(user.ID, starredFeedPurpose)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !hmac.Equal([]byte(expected), []byte(c.Param("token"))) {
+		return c.Error(404, errors.New("invalid feed link"))
+	}
+
+	return renderFeed(c, feed{
+		Title:    fmt.Sprintf("%s - starred by @%s", T.Translate(c, "app_name"), user.Nickname.String),
+		Path:     fmt.Sprintf("/feeds/starred/%s/%s", user.ID, expected),
+		HTMLPath: fmt.Sprintf("/users/%s", user.ID),
+		Where:    "id IN (SELECT text_id FROM stars WHERE user_id = ?)",
+		Args:     []interface{}{user.ID},
+	})
+}
+
+// StarredFeedURL is the signed link to the user's starred feed, in the given format
+func StarredFeedURL(userID uuid.UUID, format string) (string, error) {
+	token, err := signUserToken(userID, starredFeedPurpose)
+	if err != nil {
+		return "", err
+	}
+	return AbsoluteURL("/feeds/starred/%s/%s.%s", userID, token, format), nil
+}
+
+// renderFeed writes the feed in the format of the path. The texts are first
+// looked up without their content, so that pollers sending back the ETag or
+// Last-Modified of their last fetch get a 304 without the feed being built.
+func renderFeed(c buffalo.Context, f feed) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	format := c.Param("format")
+	if format != feedAtom && format != feedRSS {
+		return c.Error(404, errors.Errorf("unknown feed format %q", format))
+	}
+
+	query := func() *pop.Query {
+		q := tx.Where("draft = ? AND hidden = ?", false, false)
+		if f.Where != "" {
+			q = q.Where(f.Where, f.Args...)
+		}
+		return q.Order("published_at desc").Limit(feedSize)
+	}
+
+	stamps := models.Texts{}
+	if err := query().Select("id", "updated_at").All(&stamps); err != nil {
+		return errors.WithStack(err)
+	}
+	etag, updated := feedVersion(format, stamps)
+	c.Response().Header().Set("ETag", etag)
+	if !updated.IsZero() {
+		c.Response().Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request(), etag, updated) {
+		c.Response().WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	texts := models.Texts{}
+	if err := query().Eager("Author", "Tags").All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var doc interface{}
+	var err error
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == feedAtom {
+		doc, err = newAtomFeed(f, texts, updated)
+	} else {
+		contentType = "application/rss+xml; charset=utf-8"
+		doc, err = newRSSFeed(f, texts, updated)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.Func(contentType, func(w io.Writer, d render.Data) error {
+		_, err := io.WriteString(w, xml.Header+string(out))
+		return err
+	}))
+}
+
+// feedVersion returns the ETag of the feed listing the texts, it changes when a text
+// comes in or out of the feed or is edited, and when the feed was last updated
+func feedVersion(format string, texts models.Texts) (string, time.Time) {
+	var updated time.Time
+	h := sha256.New()
+	io.WriteString(h, format)
+	for _, t := range texts {
+		fmt.Fprintf(h, ":%s@%d", t.ID, t.UpdatedAt.UnixNano())
+		if t.UpdatedAt.After(updated) {
+			updated = t.UpdatedAt
+		}
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`, updated
+}
+
+// notModified checks the conditional headers of the request against the feed,
+// If-None-Match wins over If-Modified-Since like in RFC 7232
+func notModified(req *http.Request, etag string, updated time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || updated.IsZero() {
+		return false
+	}
+	return !updated.Truncate(time.Second).After(since)
+}
+
+// renderMarkdown renders the text's content as on its page
+func renderMarkdown(content string) (string, error) {
+	out, err := plush.Render("<%= markdown(content) %>", plush.NewContextWith(map[string]interface{}{
+		"content": content,
+	}))
+	return out, errors.WithStack(err)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func newAtomFeed(f feed, texts models.Texts, updated time.Time) (*atomFeed, error) {
+	doc := &atomFeed{
+		ID:      AbsoluteURL("%s", f.Path),
+		Title:   f.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: AbsoluteURL("%s.%s", f.Path, feedAtom)},
+			{Rel: "alternate", Type: "text/html", Href: AbsoluteURL("%s", f.HTMLPath)},
+		},
+	}
+	for _, t := range texts {
+		content, err := renderMarkdown(t.Content)
+		if err != nil {
+			return nil, err
+		}
+		entry := atomEntry{
+			ID:      "urn:uuid:" + t.ID.String(),
+			Title:   t.Title,
+			Updated: t.UpdatedAt.UTC().Format(time.RFC3339),
+			Author: atomPerson{
+				Name: "@" + t.Author.Nickname.String,
+				URI:  AbsoluteURL("/users/%s", t.AuthorID),
+			},
+			Links:   []atomLink{{Rel: "alternate", Type: "text/html", Href: AbsoluteURL("/texts/%s", t.ID)}},
+			Content: atomContent{Type: "html", Body: content},
+		}
+		if t.PublishedAt.Valid {
+			entry.Published = t.PublishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		for _, tag := range t.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag.Slug, Label: tag.Name})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return doc, nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func newRSSFeed(f feed, texts models.Texts, updated time.Time) (*rssFeed, error) {
+	doc := &rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        AbsoluteURL("%s", f.HTMLPath),
+			Description: f.Title,
+			AtomLink:    atomLink{Rel: "self", Type: "application/rss+xml", Href: AbsoluteURL("%s.%s", f.Path, feedRSS)},
+		},
+	}
+	if !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, t := range texts {
+		content, err := renderMarkdown(t.Content)
+		if err != nil {
+			return nil, err
+		}
+		item := rssItem{
+			Title:       t.Title,
+			Link:        AbsoluteURL("/texts/%s", t.ID),
+			GUID:        rssGUID{IsPermaLink: "false", Value: t.ID.String()},
+			Description: content,
+		}
+		if t.PublishedAt.Valid {
+			item.PubDate = t.PublishedAt.Time.UTC().Format(time.RFC1123Z)
+		}
+		for _, tag := range t.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return doc, nil
+}
+
+// feedLinks is the feed autodiscovery of the page, for the path set as "feed_path"
+// by the handler or for the global feed
+func feedLinks(help plush.HelperContext) template.HTML {
+	path, _ := help.Value("feed_path").(string)
+	if path == "" {
+		path = "/feeds/texts"
+	}
+	links := fmt.Sprintf(`<link rel="alternate" type="application/atom+xml" title="Atom" href="%s">`+"\n"+
+		`<link rel="alternate" type="application/rss+xml" title="RSS" href="%s">`,
+		template.HTMLEscapeString(AbsoluteURL("%s.%s", path, feedAtom)),
+		template.HTMLEscapeString(AbsoluteURL("%s.%s", path, feedRSS)))
+	return template.HTML(links)
+}
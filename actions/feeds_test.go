@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_TextsFeed() {
+	u := &models.User{Nickname: nulls.NewString("walker")}
+	as.NoError(as.DB.Create(u))
+	text := &models.Text{Title: "Up the ridge", Content: "Some **bold** steps", AuthorID: u.ID, PublishedAt: nulls.NewTime(time.Now())}
+	as.NoError(as.DB.Create(text))
+	as.NoError(as.DB.Create(&models.Text{Title: "Secret path", AuthorID: u.ID, Draft: true}))
+
+	res := as.HTML("/feeds/texts.atom").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/atom+xml")
+	body := res.Body.String()
+	as.Contains(body, "urn:uuid:"+text.ID.String())
+	as.Contains(body, "&lt;strong&gt;bold&lt;/strong&gt;")
+	as.Contains(body, "@walker")
+	as.NotContains(body, "Secret path")
+
+	res = as.HTML("/feeds/texts.rss").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/rss+xml")
+	as.Contains(res.Body.String(), `<guid isPermaLink="false">`+text.ID.String()+"</guid>")
+
+	res = as.HTML("/feeds/texts.json").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_TextsFeed_Conditional() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	text := &models.Text{Title: "Up the ridge", AuthorID: u.ID, PublishedAt: nulls.NewTime(time.Now())}
+	as.NoError(as.DB.Create(text))
+
+	res := as.HTML("/feeds/texts.atom").Get()
+	as.Equal(200, res.Code)
+	etag := res.Header().Get("ETag")
+	as.NotEmpty(etag)
+
+	req := as.HTML("/feeds/texts.atom")
+	req.Headers["If-None-Match"] = etag
+	res = req.Get()
+	as.Equal(304, res.Code)
+	as.Empty(res.Body.String())
+
+	req = as.HTML("/feeds/texts.atom")
+	req.Headers["If-Modified-Since"] = time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	res = req.Get()
+	as.Equal(304, res.Code)
+
+	// an edit changes the feed
+	text.Title = "Down the ridge"
+	as.NoError(as.DB.Update(text))
+	req = as.HTML("/feeds/texts.atom")
+	req.Headers["If-None-Match"] = etag
+	res = req.Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Down the ridge")
+}
+
+func (as *ActionSuite) Test_StarredFeed() {
+	reader := &models.User{}
+	author := &models.User{}
+	as.NoError(as.DB.Create(reader))
+	as.NoError(as.DB.Create(author))
+	starred := &models.Text{Title: "Up the ridge", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+	other := &models.Text{Title: "Down the valley", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+	as.NoError(as.DB.Create(starred))
+	as.NoError(as.DB.Create(other))
+	_, err := models.StarText(as.DB, reader.ID, starred)
+	as.NoError(err)
+
+	url, err := StarredFeedURL(reader.ID, feedRSS)
+	as.NoError(err)
+	res := as.HTML(url[len(AppURL):]).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Up the ridge")
+	as.NotContains(res.Body.String(), "Down the valley")
+
+	res = as.HTML("/feeds/starred/%s/forged.rss", reader.ID).Get()
+	as.Equal(404, res.Code)
+}
@@ -0,0 +1,87 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// FlagsQueue lists the pending flags for admins to review.
+// This function is mapped to the path GET /admin/flags
+func FlagsQueue(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	flags := &models.Flags{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.PaginateFromParams(c.Params()).Where("status = ?", models.FlagPending).Order("created_at asc")
+
+	// Retrieve pending flags, with the flagger and the flagged text
+	if err := q.Eager().All(flags); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+	c.Set("flags", flags)
+
+	return c.Render(200, r.HTML("admin/flags.html"))
+}
+
+// FlagDismiss closes a flag, leaving the text untouched.
+// This function is mapped to the path PUT /admin/flags/{flag_id}/dismiss
+func FlagDismiss(c buffalo.Context) error {
+	tx, flag, err := findPendingFlag(c)
+	if err != nil {
+		return err
+	}
+
+	if err := flag.Dismiss(tx, c.Value("current_user").(*models.User)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "flag.dismissed.success"))
+	return c.Redirect(302, "/admin/flags")
+}
+
+// FlagUphold hides the flagged text and penalizes its author.
+// This function is mapped to the path PUT /admin/flags/{flag_id}/uphold
+func FlagUphold(c buffalo.Context) error {
+	tx, flag, err := findPendingFlag(c)
+	if err != nil {
+		return err
+	}
+
+	if err := flag.Uphold(tx, c.Value("current_user").(*models.User)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "flag.upheld.success"))
+	return c.Redirect(302, "/admin/flags")
+}
+
+// findPendingFlag retrieves the flag from the route param flag_id,
+// flags that have already been resolved can't be resolved again
+func findPendingFlag(c buffalo.Context) (*pop.Connection, *models.Flag, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	flag := &models.Flag{}
+	if err := tx.Find(flag, c.Param("flag_id")); err != nil {
+		return nil, nil, c.Error(404, err)
+	}
+	if flag.Status != models.FlagPending {
+		return nil, nil, c.Error(409, errors.New("flag was already resolved"))
+	}
+
+	return tx, flag, nil
+}
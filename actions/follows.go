@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// FollowHandler makes the current user follow a user.
+// This function is mapped to the path POST /users/{user_id}/follow
+func FollowHandler(c buffalo.Context) error {
+	return setFollow(c, true)
+}
+
+// UnfollowHandler makes the current user stop following a user.
+// This function is mapped to the path DELETE /users/{user_id}/follow
+func UnfollowHandler(c buffalo.Context) error {
+	return setFollow(c, false)
+}
+
+// setFollow follows or unfollows the user for the current user
+// and goes back to the user's profile
+func setFollow(c buffalo.Context, follow bool) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	current := c.Value("current_user").(*models.User)
+	if current.ID == user.ID {
+		c.Flash().Add("danger", T.Translate(c, "follow.self.failure"))
+		return c.Redirect(302, "/users/%s", user.ID)
+	}
+
+	var err error
+	if follow {
+		_, err = models.FollowUser(tx, current.ID, user.ID)
+	} else {
+		_, err = models.UnfollowUser(tx, current.ID, user.ID)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Redirect(302, "/users/%s", user.ID)
+}
+
+// FollowersList lists the users following a user.
+// This function is mapped to the path GET /users/{user_id}/followers
+func FollowersList(c buffalo.Context) error {
+	return listFollows(c, models.Followers, "followers")
+}
+
+// FollowingList lists the users a user follows.
+// This function is mapped to the path GET /users/{user_id}/following
+func FollowingList(c buffalo.Context) error {
+	return listFollows(c, models.Following, "following")
+}
+
+// listFollows renders one side of a user's follow graph
+func listFollows(c buffalo.Context, query func(*pop.Connection, uuid.UUID, pop.PaginationParams) *pop.Query, kind string) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	users := &models.Users{}
+	q := query(tx, user.ID, c.Params())
+	if err := q.All(users); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("user", user)
+	c.Set("users", users)
+	c.Set("kind", kind)
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.HTML("users/follows.html"))
+}
@@ -0,0 +1,35 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_FollowHandler() {
+	alice := &models.User{}
+	bob := &models.User{}
+	as.NoError(as.DB.Create(alice))
+	as.NoError(as.DB.Create(bob))
+	as.Session.Set("current_user_id", alice.ID)
+
+	res := as.HTML("/users/%s/follow", bob.ID).Post(map[string]interface{}{})
+	as.Equal(302, res.Code)
+	following, err := models.IsFollowing(as.DB, alice.ID, bob.ID)
+	as.NoError(err)
+	as.True(following)
+
+	res = as.HTML("/users/%s/followers", bob.ID).Get()
+	as.Equal(200, res.Code)
+
+	res = as.HTML("/users/%s/follow", bob.ID).Delete()
+	as.Equal(302, res.Code)
+	following, err = models.IsFollowing(as.DB, alice.ID, bob.ID)
+	as.NoError(err)
+	as.False(following)
+
+	// no following oneself
+	res = as.HTML("/users/%s/follow", alice.ID).Post(map[string]interface{}{})
+	as.Equal(302, res.Code)
+	count, err := models.CountFollowing(as.DB, alice.ID)
+	as.NoError(err)
+	as.Equal(0, count)
+}
@@ -0,0 +1,68 @@
+package actions
+
+import (
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+// Forms are bound with c.Bind instead of the models themselves,
+// so that a crafted request can only change the fields the action
+// is meant to change: no IsAdmin, Score, SponsorID, AuthorID, etc.
+// Field names match the names used by form_for in the templates.
+
+// textForm is what an author may change on her text
+// from templates/texts/_form.html
+type textForm struct {
+	Title   string
+	Content string
+	Draft   bool
+	Tags    string
+}
+
+// apply leaves the tags unsaved, see models.SaveTextTags
+func (f textForm) apply(t *models.Text) {
+	t.Title = f.Title
+	t.Content = f.Content
+	t.Draft = f.Draft
+	t.Tags = models.ParseTags(f.Tags)
+}
+
+// profileForm is what a user may change on her profile
+// from templates/users/edit.html
+type profileForm struct {
+	Name               string
+	Nickname           string
+	Bio                string
+	DigestFrequency    string
+	EmailNotifications bool
+}
+
+func (f profileForm) apply(u *models.User) {
+	u.Name = nulls.NewString(f.Name)
+	u.Nickname = nulls.NewString(f.Nickname)
+	u.Bio = nulls.NewString(f.Bio)
+	if models.DigestPeriod(f.DigestFrequency) > 0 || f.DigestFrequency == models.DigestNever {
+		u.DigestFrequency = f.DigestFrequency
+	}
+	u.EmailNotifications = f.EmailNotifications
+}
+
+// invitationForm is what a sponsor fills in to invite someone
+// from templates/users/_form.html
+type invitationForm struct {
+	Email string
+}
+
+func (f invitationForm) apply(u *models.User) {
+	u.Email = nulls.NewString(f.Email)
+}
+
+// commentForm is what a user may write in her comment
+// from templates/texts/_comment_form.html
+type commentForm struct {
+	Content string
+}
+
+func (f commentForm) apply(c *models.Comment) {
+	c.Content = f.Content
+}
@@ -2,10 +2,33 @@ package actions
 
 import (
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
 )
 
-// HomeHandler is a default handler to serve up
-// a home page.
+// HomeHandler serves up the home page: the sign in buttons for visitors,
+// the timeline of texts from followed users for logged in users.
 func HomeHandler(c buffalo.Context) error {
+	u, ok := c.Value("current_user").(*models.User)
+	if !ok {
+		return c.Render(200, r.HTML("index.html"))
+	}
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	texts, paginator, err := models.Timeline(tx, u.ID, c.Params())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("texts", texts)
+	c.Set("pagination", paginator)
 	return c.Render(200, r.HTML("index.html"))
 }
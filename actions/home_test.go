@@ -1,7 +1,33 @@
 package actions
 
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
 func (as *ActionSuite) Test_HomeHandler() {
 	res := as.HTML("/").Get()
 	as.Equal(200, res.Code)
-	as.Contains(res.Body.String(), "Welcome to Buffalo")
+	as.Contains(res.Body.String(), "Sign in")
+}
+
+func (as *ActionSuite) Test_HomeHandler_Timeline() {
+	reader := &models.User{}
+	author := &models.User{}
+	as.NoError(as.DB.Create(reader))
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(&models.Text{Title: "From a friend", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}))
+	as.Session.Set("current_user_id", reader.ID)
+
+	res := as.HTML("/").Get()
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "From a friend")
+
+	_, err := models.FollowUser(as.DB, reader.ID, author.ID)
+	as.NoError(err)
+	res = as.HTML("/").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "From a friend")
 }
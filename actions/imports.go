@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// Importing is done in two steps: the upload is previewed without saving
+// anything (a dry run), and kept in a temporary file until the author confirms.
+// The session remembers which file, under these keys.
+const (
+	sessionImportFile = "import_file"
+	sessionImportName = "import_name"
+)
+
+// ImportNew renders the form to upload Markdown files to import as drafts.
+// This function is mapped to the path GET /texts/import
+func ImportNew(c buffalo.Context) error {
+	c.Set("max_files", models.MaxImportFiles)
+	return c.Render(200, r.HTML("texts/import.html"))
+}
+
+// ImportPreview reads the uploaded Markdown file or zip, and shows what would be
+// imported and which files can't be, without saving anything.
+// This function is mapped to the path POST /texts/import
+func ImportPreview(c buffalo.Context) error {
+	f, header, err := c.Request().FormFile("File")
+	if err != nil {
+		c.Flash().Add("danger", T.Translate(c, "import.file.missing"))
+		return c.Redirect(302, "/texts/import")
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, models.MaxImportSize+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(data) > models.MaxImportSize {
+		c.Flash().Add("danger", fmt.Sprintf(T.Translate(c, "import.file.toolarge"), models.MaxImportSize>>20))
+		return c.Redirect(302, "/texts/import")
+	}
+
+	items, err := models.ReadImport(header.Filename, data)
+	if err != nil {
+		c.Flash().Add("danger", err.Error())
+		return c.Redirect(302, "/texts/import")
+	}
+
+	// keep the upload for ImportCreate, replacing a previous one
+	discardImport(c)
+	tmp, err := ioutil.TempFile("", "kumano-import")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tmp.Close()
+	if _, err := tmp.Write(data); err != nil {
+		os.Remove(tmp.Name())
+		return errors.WithStack(err)
+	}
+	c.Session().Set(sessionImportFile, tmp.Name())
+	c.Session().Set(sessionImportName, header.Filename)
+
+	c.Set("items", items)
+	c.Set("importable", importable(items))
+	return c.Render(200, r.HTML("texts/import_preview.html"))
+}
+
+// ImportCreate saves the previewed upload's texts as drafts of the current user.
+// This function is mapped to the path POST /texts/import/confirm
+func ImportCreate(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	file, _ := c.Session().Get(sessionImportFile).(string)
+	name, _ := c.Session().Get(sessionImportName).(string)
+	if file == "" {
+		c.Flash().Add("danger", T.Translate(c, "import.file.missing"))
+		return c.Redirect(302, "/texts/import")
+	}
+	data, err := ioutil.ReadFile(file)
+	discardImport(c)
+	if err != nil {
+		c.Flash().Add("danger", T.Translate(c, "import.file.missing"))
+		return c.Redirect(302, "/texts/import")
+	}
+
+	items, err := models.ReadImport(name, data)
+	if err != nil {
+		c.Flash().Add("danger", err.Error())
+		return c.Redirect(302, "/texts/import")
+	}
+	created, err := models.SaveImport(tx, user.ID, items)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", fmt.Sprintf(T.Translate(c, "import.success"), created))
+	if skipped := len(items) - created; skipped > 0 {
+		c.Flash().Add("warning", fmt.Sprintf(T.Translate(c, "import.skipped"), skipped))
+	}
+	return c.Redirect(302, "/texts/drafts")
+}
+
+// discardImport deletes the upload kept between the preview and the import
+func discardImport(c buffalo.Context) {
+	if file, ok := c.Session().Get(sessionImportFile).(string); ok && file != "" {
+		os.Remove(file)
+	}
+	c.Session().Delete(sessionImportFile)
+	c.Session().Delete(sessionImportName)
+}
+
+// importable counts the items that can be imported
+func importable(items []models.ImportItem) int {
+	count := 0
+	for _, item := range items {
+		if item.Text != nil {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_ImportNew() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/texts/import").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "multipart/form-data")
+}
+
+func (as *ActionSuite) Test_ImportCreate_WithoutPreview() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/texts/import/confirm").Post(map[string]interface{}{})
+	as.Equal(302, res.Code)
+	as.Equal("/texts/import", res.Header().Get("Location"))
+
+	count, err := as.DB.Where("author_id = ?", u.ID).Count("texts")
+	as.NoError(err)
+	as.Equal(0, count)
+}
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/mailers"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// InvitationsList shows the current user's pending invitations,
+// with their expiry and how many she can still send.
+// This function is mapped to the path GET /invitations
+func InvitationsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	invitations, err := models.PendingInvitations(tx, c.Value("current_user").(*models.User).ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("invitations", invitations)
+	return c.Render(200, r.HTML("invitations/index.html"))
+}
+
+// InvitationResend sends the invitation again with a new link and a new expiry,
+// it doesn't consume another sponsorship.
+// This function is mapped to the path PUT /invitations/{invitation_id}/resend
+func InvitationResend(c buffalo.Context) error {
+	tx, invitee, err := findOwnInvitation(c)
+	if err != nil {
+		return err
+	}
+
+	if err := models.RenewInvitation(tx, invitee); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// admins may resend anyone's invitation, it still comes from the sponsor
+	sponsor := c.Value("current_user").(*models.User)
+	if sponsor.ID != invitee.SponsorID {
+		sponsor = &models.User{}
+		if err := tx.Find(sponsor, invitee.SponsorID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := sendInvitation(invitee, sponsor); err != nil {
+		c.Logger().Errorf("sending invitation to %s: %v", invitee.Email.String, err)
+		c.Flash().Add("danger", T.Translate(c, "users.sendinvitation.failure"))
+	} else {
+		c.Flash().Add("success", T.Translate(c, "invitation.resent.success"))
+	}
+	return c.Redirect(302, "/invitations")
+}
+
+// InvitationRevoke cancels a pending invitation and gives the sponsorship back.
+// This function is mapped to the path DELETE /invitations/{invitation_id}
+func InvitationRevoke(c buffalo.Context) error {
+	tx, invitee, err := findOwnInvitation(c)
+	if err != nil {
+		return err
+	}
+
+	if err := models.RevokeInvitation(tx, invitee); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "invitation.revoked.success"))
+	return c.Redirect(302, "/invitations")
+}
+
+// SponsorshipsGrant lets admins add invitations to a user's quota,
+// or take some back with a negative "Count".
+// This function is mapped to the path PUT /admin/users/{user_id}/sponsorships
+func SponsorshipsGrant(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, c.Param("user_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	count, err := strconv.Atoi(c.Request().FormValue("Count"))
+	if err != nil || count == 0 {
+		c.Flash().Add("danger", T.Translate(c, "invitation.grant.failure"))
+		return c.Redirect(302, "/users/%s", user.ID)
+	}
+
+	if err := models.GrantSponsorships(tx, user.ID, count); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", fmt.Sprintf(T.Translate(c, "invitation.grant.success"), count))
+	return c.Redirect(302, "/users/%s", user.ID)
+}
+
+// findOwnInvitation retrieves the pending invitation from the route param invitation_id,
+// only its sponsor, or an admin, may act on it
+func findOwnInvitation(c buffalo.Context) (*pop.Connection, *models.User, error) {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return nil, nil, errors.WithStack(errors.New("no transaction found"))
+	}
+
+	invitee := &models.User{}
+	if err := tx.Find(invitee, c.Param("invitation_id")); err != nil {
+		return nil, nil, c.Error(404, err)
+	}
+	if !invitee.InvitationPending() {
+		return nil, nil, c.Error(404, errors.New("invitation not found"))
+	}
+	if !c.Value("current_user").(*models.User).CanManage(invitee.SponsorID) {
+		return nil, nil, c.Error(403, errors.New("not your invitation"))
+	}
+
+	return tx, invitee, nil
+}
+
+// sendInvitation emails the invitation link to the invitee
+func sendInvitation(invitee *models.User, sponsor *models.User) error {
+	return mailers.SendInvitation(map[string]string{
+		"emailTo":         invitee.Email.String,
+		"invitationURL":   AbsoluteURL("/auth/invitation/%s", invitee.InvitationToken),
+		"expiresAt":       invitee.InvitationExpiresAt().Format("January 2, 2006"),
+		"sponsorName":     sponsor.Name.String,
+		"sponsorNickname": sponsor.Nickname.String,
+		"sponsorURL":      AbsoluteURL("/users/%s", invitee.SponsorID),
+	})
+}
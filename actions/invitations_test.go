@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_InvitationsList() {
+	sponsor := &models.User{}
+	as.NoError(as.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "token", InvitedAt: time.Now(), SponsorID: sponsor.ID}
+	as.NoError(as.DB.Create(invitee))
+	as.Session.Set("current_user_id", sponsor.ID)
+
+	res := as.HTML("/invitations").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "friend@example.com")
+}
+
+func (as *ActionSuite) Test_InvitationResend() {
+	sponsor := &models.User{}
+	stranger := &models.User{}
+	as.NoError(as.DB.Create(sponsor))
+	as.NoError(as.DB.Create(stranger))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "token", InvitedAt: time.Now().Add(-time.Hour), SponsorID: sponsor.ID}
+	as.NoError(as.DB.Create(invitee))
+
+	// only the sponsor may resend
+	as.Session.Set("current_user_id", stranger.ID)
+	res := as.HTML("/invitations/%s/resend", invitee.ID).Put(map[string]interface{}{})
+	as.Equal(403, res.Code)
+
+	as.Session.Set("current_user_id", sponsor.ID)
+	res = as.HTML("/invitations/%s/resend", invitee.ID).Put(map[string]interface{}{})
+	as.Equal(302, res.Code)
+
+	as.NoError(as.DB.Reload(invitee))
+	as.NotEqual("token", invitee.InvitationToken)
+	as.Len(as.Mails(), 1)
+	as.Contains(as.Mails()[0].Bodies[0].Content, invitee.InvitationToken)
+}
+
+func (as *ActionSuite) Test_InvitationRevoke() {
+	sponsor := &models.User{}
+	as.NoError(as.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "token", InvitedAt: time.Now(), SponsorID: sponsor.ID}
+	as.NoError(as.DB.Create(invitee))
+	as.Session.Set("current_user_id", sponsor.ID)
+
+	res := as.HTML("/invitations/%s", invitee.ID).Delete()
+	as.Equal(302, res.Code)
+
+	count, err := as.DB.Where("id = ?", invitee.ID).Count("users")
+	as.NoError(err)
+	as.Equal(0, count)
+	as.NoError(as.DB.Reload(sponsor))
+	as.Equal(1, sponsor.SponsorshipsCount)
+}
+
+func (as *ActionSuite) Test_InvitationRedeem_Expired() {
+	sponsor := &models.User{}
+	as.NoError(as.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "token", InvitedAt: time.Now().Add(-models.InvitationTTL - time.Hour), SponsorID: sponsor.ID}
+	as.NoError(as.DB.Create(invitee))
+
+	res := as.HTML("/auth/invitation/token").Get()
+	as.Equal(302, res.Code)
+
+	// the invitation is gone and the sponsorship back
+	count, err := as.DB.Where("id = ?", invitee.ID).Count("users")
+	as.NoError(err)
+	as.Equal(0, count)
+	as.NoError(as.DB.Reload(sponsor))
+	as.Equal(1, sponsor.SponsorshipsCount)
+}
+
+func (as *ActionSuite) Test_SponsorshipsGrant() {
+	admin := &models.User{IsAdmin: true}
+	user := &models.User{}
+	as.NoError(as.DB.Create(admin))
+	as.NoError(as.DB.Create(user))
+
+	// admins only
+	as.Session.Set("current_user_id", user.ID)
+	as.HTML("/admin/users/%s/sponsorships", user.ID).Put(map[string]interface{}{"Count": "5"})
+	as.NoError(as.DB.Reload(user))
+	as.Equal(0, user.SponsorshipsCount)
+
+	as.Session.Set("current_user_id", admin.ID)
+	res := as.HTML("/admin/users/%s/sponsorships", user.ID).Put(map[string]interface{}{"Count": "5"})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(user))
+	as.Equal(5, user.SponsorshipsCount)
+}
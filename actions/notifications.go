@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// NotificationsList shows the current user's notifications, newest first.
+// This function is mapped to the path GET /notifications
+func NotificationsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	notifications := models.Notifications{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.PaginateFromParams(c.Params()).Where("user_id = ?", user.ID).Order("created_at desc")
+	if err := q.All(&notifications); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := models.LoadNotificationDetails(tx, notifications); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("notifications", notifications)
+	c.Set("pagination", q.Paginator)
+	return c.Render(200, r.HTML("notifications/index.html"))
+}
+
+// NotificationRead marks one of the current user's notifications as read.
+// This function is mapped to the path PUT /notifications/{notification_id}/read
+func NotificationRead(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	id, err := uuid.FromString(c.Param("notification_id"))
+	if err != nil {
+		return c.Error(404, err)
+	}
+	if err := models.MarkRead(tx, user.ID, id); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Redirect(302, "/notifications")
+}
+
+// NotificationsReadAll marks all the current user's notifications as read.
+// This function is mapped to the path PUT /notifications/read
+func NotificationsReadAll(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+	user := c.Value("current_user").(*models.User)
+
+	if err := models.MarkAllRead(tx, user.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	c.Flash().Add("success", T.Translate(c, "notification.readall.success"))
+	return c.Redirect(302, "/notifications")
+}
+
+// SetUnreadNotifications middleware counts the current user's unread
+// notifications for the badge in the header
+func SetUnreadNotifications(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		count := 0
+		if u, ok := c.Value("current_user").(*models.User); ok {
+			tx := c.Value("tx").(*pop.Connection)
+			var err error
+			if count, err = models.CountUnread(tx, u.ID); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		c.Set("unread_notifications", count)
+		return next(c)
+	}
+}
@@ -0,0 +1,84 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_Notifications_Star() {
+	author := &models.User{}
+	fan := &models.User{Name: nulls.NewString("Fan")}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(fan))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+	as.NoError(as.DB.Create(text))
+
+	as.Session.Set("current_user_id", fan.ID)
+	res := as.JSON("/texts/%s/star", text.ID).Post(nil)
+	as.Equal(200, res.Code)
+	// starring again doesn't notify twice
+	res = as.JSON("/texts/%s/star", text.ID).Post(nil)
+	as.Equal(200, res.Code)
+
+	count, err := models.CountUnread(as.DB, author.ID)
+	as.NoError(err)
+	as.Equal(1, count)
+
+	as.Session.Set("current_user_id", author.ID)
+	html := as.HTML("/notifications").Get()
+	as.Equal(200, html.Code)
+	as.Contains(html.Body.String(), "starred your text")
+
+	html = as.HTML("/notifications/read").Put(map[string]interface{}{})
+	as.Equal(302, html.Code)
+	count, err = models.CountUnread(as.DB, author.ID)
+	as.NoError(err)
+	as.Equal(0, count)
+}
+
+func (as *ActionSuite) Test_StarHandler_Unpublished() {
+	author := &models.User{}
+	fan := &models.User{}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(fan))
+	draft := &models.Text{Title: "Sketch", AuthorID: author.ID, Draft: true}
+	scheduled := &models.Text{Title: "Tomorrow", AuthorID: author.ID, Draft: true, PublishedAt: nulls.NewTime(time.Now().Add(time.Hour))}
+	hidden := &models.Text{Title: "Spam", AuthorID: author.ID, Hidden: true, PublishedAt: nulls.NewTime(time.Now())}
+
+	as.Session.Set("current_user_id", fan.ID)
+	for _, text := range []*models.Text{draft, scheduled, hidden} {
+		as.NoError(as.DB.Create(text))
+		res := as.JSON("/texts/%s/star", text.ID).Post(nil)
+		as.Equal(404, res.Code)
+		count, err := models.CountStars(as.DB, text.ID)
+		as.NoError(err)
+		as.Equal(0, count)
+	}
+
+	count, err := models.CountUnread(as.DB, author.ID)
+	as.NoError(err)
+	as.Equal(0, count)
+	as.NoError(as.DB.Reload(author))
+	as.Equal(0, author.Score)
+}
+
+func (as *ActionSuite) Test_Notifications_Mention() {
+	author := &models.User{}
+	mentioned := &models.User{Nickname: nulls.NewString("mina"), ProviderID: nulls.NewString("1")}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(mentioned))
+
+	as.Session.Set("current_user_id", author.ID)
+	res := as.HTML("/texts").Post(map[string]interface{}{
+		"Title":   "Kumano Kodo",
+		"Content": "Walked it with @mina",
+		"Draft":   "false",
+	})
+	as.Equal(201, res.Code)
+
+	count, err := models.CountUnread(as.DB, mentioned.ID)
+	as.NoError(err)
+	as.Equal(1, count)
+}
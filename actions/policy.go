@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/plush"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// Only the owner of a resource, or an admin, may change it.
+// See models.User.CanManage for the rule itself.
+
+// TextOwnerRequired middleware checks the current user may manage
+// the text of the route param text_id
+func TextOwnerRequired(next buffalo.Handler) buffalo.Handler {
+	return ownerRequired(next, "text_id", func(tx *pop.Connection, id string) (uuid.UUID, error) {
+		text := &models.Text{}
+		err := tx.Find(text, id)
+		return text.AuthorID, err
+	})
+}
+
+// UserOwnerRequired middleware checks the current user may manage
+// the account of the route param user_id
+func UserOwnerRequired(next buffalo.Handler) buffalo.Handler {
+	return ownerRequired(next, "user_id", func(tx *pop.Connection, id string) (uuid.UUID, error) {
+		user := &models.User{}
+		err := tx.Find(user, id)
+		return user.ID, err
+	})
+}
+
+// ownerRequired looks up who owns the resource of the route param
+// and only lets the request through if the current user may manage it
+func ownerRequired(next buffalo.Handler, param string, owner func(*pop.Connection, string) (uuid.UUID, error)) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		// Get the DB connection from the context
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		ownerID, err := owner(tx, c.Param(param))
+		if err != nil {
+			return c.Error(404, err)
+		}
+
+		u, ok := c.Value("current_user").(*models.User)
+		if !ok || !u.CanManage(ownerID) {
+			return c.Error(403, errors.New("only the owner or an admin can do that"))
+		}
+		return next(c)
+	}
+}
+
+// canManage is the template side of the policy, e.g.
+// <%= if (can_manage(text.AuthorID)) { %>
+func canManage(ownerID uuid.UUID, help plush.HelperContext) bool {
+	if u, ok := help.Value("current_user").(*models.User); ok {
+		return u.CanManage(ownerID)
+	}
+	return false
+}
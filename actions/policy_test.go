@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_TextOwnerRequired() {
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	other := &models.User{}
+	as.NoError(as.DB.Create(other))
+	text := &models.Text{Title: "Mine", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+
+	as.Session.Set("current_user_id", other.ID)
+	res := as.HTML("/texts/%s/edit", text.ID).Get()
+	as.Equal(403, res.Code)
+	res = as.HTML("/texts/%s", text.ID).Delete()
+	as.Equal(403, res.Code)
+
+	as.Session.Set("current_user_id", author.ID)
+	res = as.HTML("/texts/%s/edit", text.ID).Get()
+	as.Equal(200, res.Code)
+}
+
+func (as *ActionSuite) Test_UserOwnerRequired() {
+	user := &models.User{}
+	as.NoError(as.DB.Create(user))
+	other := &models.User{}
+	as.NoError(as.DB.Create(other))
+	admin := &models.User{IsAdmin: true}
+	as.NoError(as.DB.Create(admin))
+
+	as.Session.Set("current_user_id", other.ID)
+	res := as.HTML("/users/%s/edit", user.ID).Get()
+	as.Equal(403, res.Code)
+
+	as.Session.Set("current_user_id", admin.ID)
+	res = as.HTML("/users/%s/edit", user.ID).Get()
+	as.Equal(200, res.Code)
+}
@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_FlagHandler_Privileges() {
+	author := &models.User{}
+	newcomer := &models.User{}
+	trusted := &models.User{Score: models.PrivilegeThreshold(models.PrivilegeSkipModeration)}
+	for _, u := range []*models.User{author, newcomer, trusted} {
+		as.NoError(as.DB.Create(u))
+	}
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+
+	// not enough points to flag yet
+	as.Session.Set("current_user_id", newcomer.ID)
+	res := as.HTML("/texts/%s/flag", text.ID).Post(map[string]interface{}{"Reason": "spam"})
+	as.Equal(302, res.Code)
+	count, err := as.DB.Where("text_id = ?", text.ID).Count("flags")
+	as.NoError(err)
+	as.Equal(0, count)
+
+	// a trusted user alone can't take the text down
+	as.Session.Set("current_user_id", trusted.ID)
+	res = as.HTML("/texts/%s/flag", text.ID).Post(map[string]interface{}{"Reason": "spam"})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(text))
+	as.Equal(models.TrustedFlagsToHide <= 1, text.Hidden)
+
+	// enough of them take it down right away
+	for i := 1; i < models.TrustedFlagsToHide; i++ {
+		other := &models.User{Score: trusted.Score}
+		as.NoError(as.DB.Create(other))
+		as.Session.Set("current_user_id", other.ID)
+		res = as.HTML("/texts/%s/flag", text.ID).Post(map[string]interface{}{"Reason": "spam"})
+		as.Equal(302, res.Code)
+	}
+	as.NoError(as.DB.Reload(text))
+	as.True(text.Hidden)
+}
+
+func (as *ActionSuite) Test_CommentCreate_Privileges() {
+	author := &models.User{}
+	penalized := &models.User{Score: models.PrivilegeThreshold(models.PrivilegeComment) - 1}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(penalized))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+
+	as.Session.Set("current_user_id", penalized.ID)
+	res := as.HTML("/texts/%s/comments", text.ID).Post(map[string]interface{}{"Content": "Hello"})
+	as.Equal(302, res.Code)
+	count, err := as.DB.Where("text_id = ?", text.ID).Count("comments")
+	as.NoError(err)
+	as.Equal(0, count)
+}
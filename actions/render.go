@@ -24,21 +24,42 @@ func init() {
 			// uncomment for non-Bootstrap form helpers:
 			// "form":     plush.FormHelper,
 			// "form_for": plush.FormForHelper,
-			"can_invite":   canInvite,
-			"is_admin":     isAdmin,
-			"is_logged_in": isLoggedIn,
-			"is_self":      isSelf,
+			"can_invite":    canInvite,
+			"canonical_url": canonicalURL,
+			"can_manage":    canManage,
+			"feed_links":    feedLinks,
+			"has_privilege": hasPrivilege,
+			"is_admin":      isAdmin,
+			"is_logged_in":  isLoggedIn,
+			"is_self":       isSelf,
+			"points_to":     pointsTo,
 		},
 	})
 }
 
 func canInvite(help plush.HelperContext) bool {
 	if help.Value("current_user") != nil {
-		return help.Value("current_user").(*models.User).IsAdmin || help.Value("current_user").(*models.User).SponsorshipsCount > 0
+		return help.Value("current_user").(*models.User).CanInvite()
 	}
 	return false
 }
 
+// the current user has unlocked the privilege, see models.Privileges
+func hasPrivilege(name string, help plush.HelperContext) bool {
+	if u, ok := help.Value("current_user").(*models.User); ok {
+		return u.Can(name)
+	}
+	return false
+}
+
+// how many points the current user still needs for the privilege
+func pointsTo(name string, help plush.HelperContext) int {
+	if u, ok := help.Value("current_user").(*models.User); ok {
+		return u.PointsTo(name)
+	}
+	return models.PrivilegeThreshold(name)
+}
+
 func isAdmin(help plush.HelperContext) bool {
 	if help.Value("current_user") != nil {
 		return help.Value("current_user").(*models.User).IsAdmin
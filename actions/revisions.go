@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// RevisionsList shows the history of a text and a line-level diff
+// between two of its revisions, picked with the "from" and "to" params
+// (defaults to the last two revisions).
+// This function is mapped to the path GET /texts/{text_id}/revisions
+func RevisionsList(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	// the history of drafts and hidden texts is as private as the texts themselves
+	if text.Draft || text.Hidden {
+		u, ok := c.Value("current_user").(*models.User)
+		if !ok || !u.CanManage(text.AuthorID) {
+			return c.Error(404, errors.New("text not found"))
+		}
+	}
+
+	revisions := models.TextRevisions{}
+	if err := tx.Eager("Author").Where("text_id = ?", text.ID).Order("created_at desc").All(&revisions); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// pick the two revisions to compare
+	var from, to *models.TextRevision
+	for i := range revisions {
+		switch revisions[i].ID.String() {
+		case c.Param("from"):
+			from = &revisions[i]
+		case c.Param("to"):
+			to = &revisions[i]
+		}
+	}
+	if to == nil && len(revisions) > 0 {
+		to = &revisions[0]
+	}
+	if from == nil && len(revisions) > 1 {
+		from = &revisions[1]
+	}
+
+	diff := []models.DiffLine{}
+	fromID, toID := "", ""
+	comparable := true
+	if from != nil && to != nil {
+		diff, comparable = models.LineDiff(from.Title+"\n\n"+from.Content, to.Title+"\n\n"+to.Content)
+		fromID, toID = from.ID.String(), to.ID.String()
+	}
+
+	c.Set("text", text)
+	c.Set("revisions", revisions)
+	c.Set("from_id", fromID)
+	c.Set("to_id", toID)
+	c.Set("diff", diff)
+	c.Set("comparable", comparable)
+	return c.Render(200, r.HTML("texts/revisions.html"))
+}
+
+// RevisionRestore puts an earlier revision back on the text, as a new revision.
+// This function is mapped to the path POST /texts/{text_id}/revisions/{revision_id}/restore
+func RevisionRestore(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	text := &models.Text{}
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	revision := &models.TextRevision{}
+	if err := tx.Where("id = ? AND text_id = ?", c.Param("revision_id"), text.ID).First(revision); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := revision.Restore(tx, text, c.Value("current_user").(*models.User).ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "text.revision.restored"))
+	return c.Redirect(302, "/texts/%s", text.ID)
+}
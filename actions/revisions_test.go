@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_RevisionsList() {
+	author := &models.User{Nickname: nulls.NewString("walker")}
+	as.NoError(as.DB.Create(author))
+	stranger := &models.User{}
+	as.NoError(as.DB.Create(stranger))
+
+	text := &models.Text{Title: "Kumano", Content: "Kodo", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+	as.NoError(models.RecordRevision(as.DB, text, author.ID))
+	text.Content = "Kodo, the old way"
+	as.NoError(as.DB.Update(text))
+	as.NoError(models.RecordRevision(as.DB, text, author.ID))
+
+	// the history of a published text is public
+	res := as.HTML("/texts/%s/revisions", text.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "the old way")
+
+	// drafts and hidden texts only show to their author
+	for _, hide := range []func(){
+		func() { text.Draft = true },
+		func() { text.Draft = false; text.Hidden = true },
+	} {
+		hide()
+		as.NoError(as.DB.Update(text))
+
+		as.Session.Clear()
+		res = as.HTML("/texts/%s/revisions", text.ID).Get()
+		as.Equal(404, res.Code)
+
+		as.Session.Set("current_user_id", stranger.ID)
+		res = as.HTML("/texts/%s/revisions", text.ID).Get()
+		as.Equal(404, res.Code)
+
+		as.Session.Set("current_user_id", author.ID)
+		res = as.HTML("/texts/%s/revisions", text.ID).Get()
+		as.Equal(200, res.Code)
+	}
+}
+
+func (as *ActionSuite) Test_RevisionRestore() {
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	stranger := &models.User{}
+	as.NoError(as.DB.Create(stranger))
+
+	text := &models.Text{Title: "Kumano", Content: "Kodo", AuthorID: author.ID}
+	as.NoError(as.DB.Create(text))
+	as.NoError(models.RecordRevision(as.DB, text, author.ID))
+	first := &models.TextRevision{}
+	as.NoError(as.DB.Where("text_id = ?", text.ID).First(first))
+	text.Content = "Kodo, the old way"
+	as.NoError(as.DB.Update(text))
+	as.NoError(models.RecordRevision(as.DB, text, author.ID))
+
+	// only the author may restore
+	as.Session.Set("current_user_id", stranger.ID)
+	res := as.HTML("/texts/%s/revisions/%s/restore", text.ID, first.ID).Post(nil)
+	as.Equal(403, res.Code)
+
+	as.Session.Set("current_user_id", author.ID)
+	res = as.HTML("/texts/%s/revisions/%s/restore", text.ID, first.ID).Post(nil)
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(text))
+	as.Equal("Kodo", text.Content)
+}
@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// SearchHandler searches published texts and users.
+// Param "q" is the query, "type" picks the results to list ("texts" or "users"),
+// "page" and "per_page" control pagination of the listed results.
+// This function is mapped to the path GET /search
+func SearchHandler(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	query := strings.TrimSpace(c.Param("q"))
+	kind := c.Param("type")
+	if kind != "users" {
+		kind = "texts"
+	}
+	c.Set("q", query)
+	c.Set("type", kind)
+	c.Set("texts", models.Texts{})
+	c.Set("users", models.Users{})
+	c.Set("texts_count", 0)
+	c.Set("users_count", 0)
+	c.Set("pagination", pop.NewPaginator(1, 20))
+
+	if query == "" {
+		return c.Render(200, r.HTML("search/index.html"))
+	}
+
+	// the listed results get the requested page, the other ones are only counted
+	p := pop.NewPaginatorFromParams(c.Params())
+	textsPage, usersPage := p.Page, 1
+	if kind == "users" {
+		textsPage, usersPage = 1, p.Page
+	}
+
+	texts, tp, err := models.SearchTexts(tx, query, textsPage, p.PerPage)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	users, up, err := models.SearchUsers(tx, query, usersPage, p.PerPage)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("texts", texts)
+	c.Set("users", users)
+	c.Set("texts_count", tp.TotalEntriesSize)
+	c.Set("users_count", up.TotalEntriesSize)
+	if kind == "users" {
+		c.Set("pagination", up)
+	} else {
+		c.Set("pagination", tp)
+	}
+
+	return c.Render(200, r.HTML("search/index.html"))
+}
@@ -0,0 +1,21 @@
+package actions
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_SearchHandler() {
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	as.NoError(as.DB.Create(&models.Text{Title: "Kumano Kodo", Content: "A pilgrimage", AuthorID: author.ID}))
+	as.NoError(as.DB.Create(&models.Text{Title: "Kumano draft", Content: "Not yet", AuthorID: author.ID, Draft: true}))
+
+	res := as.HTML("/search?q=kumano").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Kumano Kodo")
+	as.NotContains(res.Body.String(), "Kumano draft")
+
+	res = as.HTML("/search?q=nothing+here").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "No text matches")
+}
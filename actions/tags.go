@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// the tag cloud shows the tags used over this period
+const tagCloudPeriod = 30 * 24 * time.Hour
+
+// TagsIndex shows the tag cloud of the last 30 days.
+// This function is mapped to the path GET /tags
+func TagsIndex(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	cloud, err := models.TagCloud(tx, time.Now().Add(-tagCloudPeriod), 100)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("cloud", cloud)
+	return c.Render(200, r.HTML("tags/index.html"))
+}
+
+// TagShow lists the published texts with the tag.
+// This function is mapped to the path GET /tags/{slug}
+func TagShow(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tag := &models.Tag{}
+	if err := tx.Where("slug = ?", c.Param("slug")).First(tag); err != nil {
+		return c.Error(404, err)
+	}
+
+	texts := &models.Texts{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := models.TaggedTexts(tx, tag.ID, c.Params())
+	if err := q.Eager().All(texts); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+	c.Set("tag", tag)
+	c.Set("texts", texts)
+	c.Set("feed_path", fmt.Sprintf("/feeds/tags/%s", tag.Slug))
+	return c.Render(200, r.HTML("tags/show.html"))
+}
+
+// TagsSuggest answers the tag input's autocompletion with the names
+// of the tags starting like param "q".
+// This function is mapped to the path GET /tags/suggest
+func TagsSuggest(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tags, err := models.SuggestTags(tx, c.Param("q"), 10)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return c.Render(200, r.JSON(names))
+}
+
+// TagsAdmin lists every tag with how many texts have it, for admins to rename or merge them.
+// This function is mapped to the path GET /admin/tags
+func TagsAdmin(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	usage, err := models.AllTagUsage(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("tags", usage)
+	return c.Render(200, r.HTML("admin/tags.html"))
+}
+
+// TagRename gives a tag the new "Name".
+// This function is mapped to the path PUT /admin/tags/{tag_id}
+func TagRename(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	tag := &models.Tag{}
+	if err := tx.Find(tag, c.Param("tag_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	verrs, err := models.RenameTag(tx, tag, c.Request().FormValue("Name"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		for _, msgs := range verrs.Errors {
+			for _, msg := range msgs {
+				c.Flash().Add("danger", msg)
+			}
+		}
+		return c.Redirect(302, "/admin/tags")
+	}
+
+	c.Flash().Add("success", T.Translate(c, "tag.renamed.success"))
+	return c.Redirect(302, "/admin/tags")
+}
+
+// TagMerge moves the texts of a tag over to the tag named "Into", then deletes it.
+// This function is mapped to the path POST /admin/tags/{tag_id}/merge
+func TagMerge(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	from := &models.Tag{}
+	if err := tx.Find(from, c.Param("tag_id")); err != nil {
+		return c.Error(404, err)
+	}
+	into := &models.Tag{}
+	if err := tx.Where("slug = ?", models.Slugify(c.Request().FormValue("Into"))).First(into); err != nil || into.ID == from.ID {
+		c.Flash().Add("danger", T.Translate(c, "tag.merge.failure"))
+		return c.Redirect(302, "/admin/tags")
+	}
+
+	if err := models.MergeTags(tx, from, into); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "tag.merged.success"))
+	return c.Redirect(302, "/admin/tags")
+}
@@ -0,0 +1,55 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (as *ActionSuite) Test_TagShow() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	published := &models.Text{Title: "Up the ridge", AuthorID: u.ID, PublishedAt: nulls.NewTime(time.Now())}
+	draft := &models.Text{Title: "Secret path", AuthorID: u.ID, Draft: true}
+	for _, t := range []*models.Text{published, draft} {
+		as.NoError(as.DB.Create(t))
+		t.Tags = models.ParseTags("hiking")
+		as.NoError(models.SaveTextTags(as.DB, t))
+	}
+
+	res := as.HTML("/tags/hiking").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Up the ridge")
+	as.NotContains(res.Body.String(), "Secret path")
+
+	res = as.HTML("/tags/nope").Get()
+	as.Equal(404, res.Code)
+}
+
+func (as *ActionSuite) Test_TagsSuggest() {
+	as.NoError(as.DB.Create(&models.Tag{Name: "Hiking", Slug: "hiking"}))
+	as.NoError(as.DB.Create(&models.Tag{Name: "Skiing", Slug: "skiing"}))
+
+	res := as.JSON("/tags/suggest?q=hi").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), `"Hiking"`)
+	as.NotContains(res.Body.String(), "Skiing")
+}
+
+func (as *ActionSuite) Test_TagMerge() {
+	admin := &models.User{IsAdmin: true}
+	as.NoError(as.DB.Create(admin))
+	from := &models.Tag{Name: "hike", Slug: "hike"}
+	into := &models.Tag{Name: "hiking", Slug: "hiking"}
+	as.NoError(as.DB.Create(from))
+	as.NoError(as.DB.Create(into))
+	as.Session.Set("current_user_id", admin.ID)
+
+	res := as.HTML("/admin/tags/%s/merge", from.ID).Post(map[string]interface{}{"Into": "Hiking"})
+	as.Equal(302, res.Code)
+
+	count, err := as.DB.Where("id = ?", from.ID).Count("tags")
+	as.NoError(err)
+	as.Equal(0, count)
+}
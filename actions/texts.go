@@ -2,6 +2,7 @@ package actions
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/gobuffalo/uuid"
@@ -9,6 +10,7 @@ import (
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/validate"
 	"github.com/nicomo/kumano/models"
 	"github.com/pkg/errors"
 )
@@ -43,7 +45,7 @@ func (v TextsResource) List(c buffalo.Context) error {
 
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params()).Where("draft = ?", false)
+	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND hidden = ?", false, false)
 
 	// Retrieve all Texts from the DB
 	if err := q.Eager().All(texts); err != nil {
@@ -52,6 +54,7 @@ func (v TextsResource) List(c buffalo.Context) error {
 
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
+	c.Set("feed_path", "/feeds/texts")
 
 	return c.Render(200, r.Auto(c, texts))
 }
@@ -66,7 +69,7 @@ func (v TextsResource) ListDrafts(c buffalo.Context) error {
 	}
 
 	texts := &models.Texts{}
-	uID := c.Session().Get("current_user_id").(uuid.UUID)
+	uID := c.Value("current_user").(*models.User).ID
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
 	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND author_id= ?", true, uID).Order("created_at desc")
@@ -100,7 +103,7 @@ func (v TextsResource) ListUserTexts(c buffalo.Context) error {
 	texts := &models.Texts{}
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND author_id= ?", false, c.Param("user_id")).Order("created_at desc")
+	q := tx.PaginateFromParams(c.Params()).Where("draft = ? AND hidden = ? AND author_id= ?", false, false, c.Param("user_id")).Order("created_at desc")
 
 	// Retrieve all Texts from the DB
 	if err := q.Eager().All(texts); err != nil {
@@ -109,6 +112,7 @@ func (v TextsResource) ListUserTexts(c buffalo.Context) error {
 
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
+	c.Set("feed_path", fmt.Sprintf("/feeds/users/%s", c.Param("user_id")))
 
 	// FIXME: should simply be return c.Render(200, r.Auto(c, texts))
 	// which is exactly what List() uses
@@ -135,21 +139,51 @@ func (v TextsResource) Show(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
+	// texts taken down by moderation are only visible to their author and admins
+	if text.Hidden {
+		u, ok := c.Value("current_user").(*models.User)
+		if !ok || (!u.IsAdmin && u.ID != text.AuthorID) {
+			return c.Error(404, errors.New("text not found"))
+		}
+	}
+	c.Set("flag_reasons", models.FlagReasons)
+
+	// has the current user already starred this text?
+	starred := false
+	if u, ok := c.Value("current_user").(*models.User); ok {
+		var err error
+		if starred, err = models.HasStarred(tx, u.ID, text.ID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	c.Set("starred", starred)
+
+	comments, err := models.CommentThread(tx, text.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("comments", comments)
+
 	return c.Render(200, r.Auto(c, text))
 }
 
 // New renders the form for creating a new Text.
 // This function is mapped to the path GET /texts/new
 func (v TextsResource) New(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
 	user := c.Value("current_user").(*models.User)
-	ok := user.CanPost()
-	if ok {
-		// can edit post regardless
-		c.Set("user_can_post", true)
-	} else {
-		c.Set("user_can_post", false)
-		diff := time.Since(user.LastPostedAt)
-		c.Flash().Add("info", fmt.Sprintf("Slow down (last post was %s ago). You can still work on drafts though.", diff.Truncate(time.Second).String()))
+	ok, wait, err := user.CanPost(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set("user_can_post", ok)
+	if !ok {
+		c.Flash().Add("info", slowDown(c, wait))
 	}
 	return c.Render(200, r.Auto(c, &models.Text{}))
 }
@@ -162,12 +196,15 @@ func (v TextsResource) Create(c buffalo.Context) error {
 	user := c.Value("current_user").(*models.User)
 
 	// Bind text to the html form elements
-	if err := c.Bind(text); err != nil {
+	form := textForm{}
+	if err := c.Bind(&form); err != nil {
 		return errors.WithStack(err)
 	}
+	form.apply(text)
 	text.AuthorID = user.ID
-	if !text.Draft {
-		text.PublishedAt = nulls.NewTime(time.Now())
+	publishAt, err := publishAtParam(c)
+	if err != nil {
+		return c.Error(400, err)
 	}
 
 	// Get the DB connection from the context
@@ -176,6 +213,24 @@ func (v TextsResource) Create(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
+	// publishing right away counts against the posting quota
+	if !text.Draft && !publishAt.After(time.Now()) {
+		allowed, err := postingAllowed(c, tx, user)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return c.Render(429, r.Auto(c, text))
+		}
+	}
+
+	live := false
+	if text.Draft {
+		text.PublishedAt = nulls.Time{}
+	} else {
+		live = publishOrSchedule(text, publishAt)
+	}
+
 	// Validate the data from the html form
 	verrs, err := tx.ValidateAndCreate(text)
 	if err != nil {
@@ -191,21 +246,79 @@ func (v TextsResource) Create(c buffalo.Context) error {
 		return c.Render(422, r.Auto(c, text))
 	}
 
-	// If there are no errors set a success message
-	c.Flash().Add("success", T.Translate(c, "text.created.success"))
+	if err := models.RecordRevision(tx, text, user.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := models.SaveTextTags(tx, text); err != nil {
+		return errors.WithStack(err)
+	}
 
-	// Add points + date last posted to user
-	user.Score += models.PointsPosts
-	user.LastPostedAt = time.Now()
-	if err := tx.Update(user); err != nil {
-		// TODO: log err server side
-		c.Flash().Add("danger", T.Translate(c, "user.postcredit.failure"))
+	// Add points + date last posted to user, scheduled texts
+	// get theirs when they actually go live
+	if live {
+		if err := models.TextWentLive(tx, user, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	// If there are no errors set a success message
+	if text.Scheduled() {
+		c.Flash().Add("success", fmt.Sprintf(T.Translate(c, "text.scheduled.success"), text.PublishedAt.Time.Format("2006-01-02 15:04")))
+	} else {
+		c.Flash().Add("success", T.Translate(c, "text.created.success"))
 	}
 
 	// and redirect to the texts index page
 	return c.Render(201, r.Auto(c, text))
 }
 
+// publishOrSchedule publishes the text right away, or schedules it if
+// publishAt is in the future: the text then stays a draft until
+// models.PublishDueTexts promotes it. Returns true if the text went live.
+func publishOrSchedule(text *models.Text, publishAt time.Time) bool {
+	if publishAt.After(time.Now()) {
+		text.Draft = true
+		text.PublishedAt = nulls.NewTime(publishAt)
+		return false
+	}
+	text.Draft = false
+	text.PublishedAt = nulls.NewTime(time.Now())
+	return true
+}
+
+// postingAllowed checks the user about to publish a text is within her posting quota.
+// When she isn't, the errors telling her how long to wait are set for the template.
+func postingAllowed(c buffalo.Context, tx *pop.Connection, user *models.User) (bool, error) {
+	ok, wait, err := user.CanPost(tx)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if !ok {
+		verrs := validate.NewErrors()
+		verrs.Add("posting", slowDown(c, wait))
+		c.Set("errors", verrs)
+		c.Set("user_can_post", false)
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	}
+	return ok, nil
+}
+
+// slowDown tells the user how long she has to wait before publishing again
+func slowDown(c buffalo.Context, wait time.Duration) string {
+	return fmt.Sprintf(T.Translate(c, "text.posting.limited"), wait.Truncate(time.Minute)+time.Minute)
+}
+
+// publishAtParam reads the optional publication date of the text form,
+// as sent by a datetime-local input
+func publishAtParam(c buffalo.Context) (time.Time, error) {
+	v := c.Request().FormValue("PublishAt")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", v, time.Local)
+	return t, errors.WithStack(err)
+}
+
 // Edit renders a edit form for a Text. This function is
 // mapped to the path GET /texts/{text_id}/edit
 func (v TextsResource) Edit(c buffalo.Context) error {
@@ -219,18 +332,24 @@ func (v TextsResource) Edit(c buffalo.Context) error {
 	// Allocate an empty Text
 	text := &models.Text{}
 
-	if err := tx.Find(text, c.Param("text_id")); err != nil {
+	if err := tx.Eager("Tags").Find(text, c.Param("text_id")); err != nil {
 		return c.Error(404, err)
 	}
-	user := c.Value("current_user").(*models.User)
-	ok = user.CanPost()
-	if !text.Draft || ok {
-		// can edit post regardless
+	// admins may edit someone else's text, the posting quota is the author's
+	author, err := textAuthor(c, tx, text)
+	if err != nil {
+		return err
+	}
+	ok, wait, err := author.CanPost(tx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !text.Draft || text.Scheduled() || text.FirstPublishedAt.Valid || ok {
+		// can edit post regardless, and neither scheduling nor republishing counts against the quota
 		c.Set("user_can_post", true)
 	} else {
 		c.Set("user_can_post", false)
-		diff := time.Since(user.LastPostedAt)
-		c.Flash().Add("info", fmt.Sprintf("Slow down (last post was %s ago). You can still work on drafts though.", diff.Truncate(time.Second).String()))
+		c.Flash().Add("info", slowDown(c, wait))
 	}
 
 	return c.Render(200, r.Auto(c, text))
@@ -251,11 +370,48 @@ func (v TextsResource) Update(c buffalo.Context) error {
 	if err := tx.Find(text, c.Param("text_id")); err != nil {
 		return c.Error(404, err)
 	}
+	wasLive := !text.Draft
+	wasScheduled := text.Scheduled()
+	author, err := textAuthor(c, tx, text)
+	if err != nil {
+		return err
+	}
 
 	// Bind Text to the html form elements
-	if err := c.Bind(text); err != nil {
+	form := textForm{}
+	if err := c.Bind(&form); err != nil {
 		return errors.WithStack(err)
 	}
+	form.apply(text)
+
+	// publishing a draft, now or later
+	publishAt, err := publishAtParam(c)
+	if err != nil {
+		return c.Error(400, err)
+	}
+	// a draft going live right away counts against the posting quota,
+	// unless it already did the first time it went live
+	if !text.Draft && !wasLive && !text.FirstPublishedAt.Valid && !publishAt.After(time.Now()) {
+		allowed, err := postingAllowed(c, tx, author)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return c.Render(429, r.Auto(c, text))
+		}
+	}
+
+	live := false
+	if text.Draft {
+		// a scheduled text saved as draft keeps its date, unless it was emptied
+		if wasScheduled && publishAt.After(time.Now()) {
+			text.PublishedAt = nulls.NewTime(publishAt)
+		} else {
+			text.PublishedAt = nulls.Time{}
+		}
+	} else if !wasLive {
+		live = publishOrSchedule(text, publishAt)
+	}
 
 	verrs, err := tx.ValidateAndUpdate(text)
 	if err != nil {
@@ -271,6 +427,19 @@ func (v TextsResource) Update(c buffalo.Context) error {
 		return c.Render(422, r.Auto(c, text))
 	}
 
+	if err := models.RecordRevision(tx, text, c.Value("current_user").(*models.User).ID); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := models.SaveTextTags(tx, text); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if live {
+		if err := models.TextWentLive(tx, author, text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	// If there are no errors set a success message
 	c.Flash().Add("success", "Text was updated successfully")
 
@@ -278,6 +447,18 @@ func (v TextsResource) Update(c buffalo.Context) error {
 	return c.Render(200, r.Auto(c, text))
 }
 
+// textAuthor returns the author of the text, which is usually the current user
+func textAuthor(c buffalo.Context, tx *pop.Connection, text *models.Text) (*models.User, error) {
+	if u, ok := c.Value("current_user").(*models.User); ok && u.ID == text.AuthorID {
+		return u, nil
+	}
+	author := &models.User{}
+	if err := tx.Find(author, text.AuthorID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return author, nil
+}
+
 // Destroy deletes a Text from the DB. This function is mapped
 // to the path DELETE /texts/{text_id}
 func (v TextsResource) Destroy(c buffalo.Context) error {
@@ -295,7 +476,7 @@ func (v TextsResource) Destroy(c buffalo.Context) error {
 		return c.Error(404, err)
 	}
 
-	if err := tx.Destroy(text); err != nil {
+	if err := models.DeleteText(tx, text); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -306,8 +487,22 @@ func (v TextsResource) Destroy(c buffalo.Context) error {
 	return c.Render(200, r.Auto(c, text))
 }
 
-// StarHandler when a user stars a text
+// StarHandler when a user stars a text. Starring twice is harmless,
+// see UnstarHandler for taking the star back.
+// This function is mapped to the path POST /texts/{text_id}/star
 func StarHandler(c buffalo.Context) error {
+	return setStar(c, true)
+}
+
+// UnstarHandler when a user takes back her star.
+// This function is mapped to the path DELETE /texts/{text_id}/star
+func UnstarHandler(c buffalo.Context) error {
+	return setStar(c, false)
+}
+
+// setStar stars or unstars the text for the current user
+// and renders the new star status as JSON for the star button
+func setStar(c buffalo.Context, starred bool) error {
 
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -321,28 +516,110 @@ func StarHandler(c buffalo.Context) error {
 	if err := tx.Find(text, c.Param("text_id")); err != nil {
 		return c.Error(404, err)
 	}
+	// drafts, scheduled and hidden texts can't be seen, so they can't be starred either
+	if text.Draft || text.Hidden {
+		return c.Error(404, errors.New("text not found"))
+	}
 
-	star := &models.Star{
-		ID:        uuid.Must(uuid.NewV4()),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    c.Session().Get("current_user_id").(uuid.UUID),
-		TextID:    text.ID,
+	user := c.Value("current_user").(*models.User)
+	if user.ID == text.AuthorID {
+		return c.Render(403, r.JSON(map[string]string{"error": "you can't star your own text"}))
 	}
 
-	// TODO: userid+textid should be unique
+	var err error
+	if starred {
+		err = starText(tx, user, text)
+	} else {
+		_, err = models.UnstarText(tx, user.ID, text)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
-	verrs, err := tx.ValidateAndCreate(star)
+	count, err := models.CountStars(tx, text.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"starred": starred,
+		"count":   count,
+	}))
+}
+
+// starText stars the text for the user and lets its author know
+func starText(tx *pop.Connection, user *models.User, text *models.Text) error {
+	added, err := models.StarText(tx, user.ID, text)
+	if err != nil || !added {
+		return err
+	}
+	return models.Notify(tx, &models.Notification{
+		UserID:  text.AuthorID,
+		ActorID: user.ID,
+		Kind:    models.NotificationStarred,
+		TextID:  nulls.NewUUID(text.ID),
+	})
+}
+
+// FlagHandler when a user flags a text for moderation
+func FlagHandler(c buffalo.Context) error {
+
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	// Allocate an empty Text
+	text := &models.Text{}
+
+	if err := tx.Find(text, c.Param("text_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	user := c.Value("current_user").(*models.User)
+	if user.ID == text.AuthorID {
+		c.Flash().Add("danger", T.Translate(c, "flag.own.failure"))
+		return c.Redirect(302, "/texts/%s", text.ID)
+	}
+	if !user.Can(models.PrivilegeFlag) {
+		c.Flash().Add("danger", fmt.Sprintf(T.Translate(c, "privilege.flag.locked"), user.PointsTo(models.PrivilegeFlag)))
+		return c.Redirect(302, "/texts/%s", text.ID)
+	}
+
+	flag := &models.Flag{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: user.ID,
+		TextID: text.ID,
+		Reason: c.Request().FormValue("Reason"),
+		Status: models.FlagPending,
+	}
+
+	verrs, err := tx.ValidateAndCreate(flag)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	if verrs.HasAny() {
-		// Make the errors available inside the html template
-		c.Set("errors", verrs)
-		fmt.Printf("\n#### verrs ####\n%v\n#### star ####\n", verrs)
-		// Render again.
-		return c.Render(422, r.Auto(c, text))
+		for _, msgs := range verrs.Errors {
+			for _, msg := range msgs {
+				c.Flash().Add("danger", msg)
+			}
+		}
+		return c.Redirect(302, "/texts/%s", text.ID)
+	}
+
+	// enough trusted users don't wait for an admin to take the text down
+	if user.Can(models.PrivilegeSkipModeration) {
+		hidden, err := flag.HideUntilReviewed(tx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if hidden {
+			c.Flash().Add("success", T.Translate(c, "flag.hidden.success"))
+			return c.Redirect(302, "/texts/%s", text.ID)
+		}
 	}
 
-	return c.Redirect(200, "/")
+	c.Flash().Add("success", T.Translate(c, "flag.created.success"))
+	return c.Redirect(302, "/texts/%s", text.ID)
 }
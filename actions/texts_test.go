@@ -1,9 +1,34 @@
 package actions
 
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
 func (as *ActionSuite) Test_TextsResource_List() {
 	as.Fail("Not Implemented!")
 }
 
+func (as *ActionSuite) Test_TextsResource_ListDrafts() {
+	u := &models.User{}
+	as.NoError(as.DB.Create(u))
+	t, plain, err := models.NewAPIToken(u.ID, "test")
+	as.NoError(err)
+	as.NoError(as.DB.Create(t))
+	as.NoError(as.DB.Create(&models.Text{Title: "Up the ridge", AuthorID: u.ID}))
+	as.NoError(as.DB.Create(&models.Text{Title: "Sketch", AuthorID: u.ID, Draft: true}))
+
+	// authenticated with a token rather than the session
+	req := as.HTML("/texts/drafts")
+	req.Headers["Authorization"] = "Bearer " + plain
+	res := req.Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Sketch")
+	as.NotContains(res.Body.String(), "Up the ridge")
+}
+
 func (as *ActionSuite) Test_TextsResource_Show() {
 	as.Fail("Not Implemented!")
 }
@@ -17,11 +42,111 @@ func (as *ActionSuite) Test_TextsResource_Create() {
 }
 
 func (as *ActionSuite) Test_TextsResource_Edit() {
-	as.Fail("Not Implemented!")
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	at := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+	scheduled := &models.Text{Title: "Tomorrow", Content: "Soon", AuthorID: author.ID, Draft: true, PublishedAt: nulls.NewTime(at)}
+	as.NoError(as.DB.Create(scheduled))
+	as.Session.Set("current_user_id", author.ID)
+
+	// at quota, a scheduled text can still be saved with its schedule
+	for i := 0; i < 10; i++ {
+		ok, _, err := author.CanPost(as.DB)
+		as.NoError(err)
+		if !ok {
+			break
+		}
+		text := &models.Text{Title: "Posted", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+		as.NoError(as.DB.Create(text))
+		as.NoError(models.TextWentLive(as.DB, author, text))
+	}
+	res := as.HTML("/texts/%s/edit", scheduled.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), `name="Draft" value="false"`)
+
+	// saving it as draft keeps the schedule
+	res = as.HTML("/texts/%s", scheduled.ID).Put(map[string]interface{}{
+		"Title":     "Tomorrow",
+		"Content":   "Sooner",
+		"Draft":     "true",
+		"PublishAt": at.Format("2006-01-02T15:04"),
+	})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(scheduled))
+	as.Equal("Sooner", scheduled.Content)
+	as.True(scheduled.Scheduled())
+	as.WithinDuration(at, scheduled.PublishedAt.Time, time.Minute)
+
+	// emptying the date unschedules it
+	res = as.HTML("/texts/%s", scheduled.ID).Put(map[string]interface{}{
+		"Title":   "Tomorrow",
+		"Content": "Sooner",
+		"Draft":   "true",
+	})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(scheduled))
+	as.True(scheduled.Draft)
+	as.False(scheduled.PublishedAt.Valid)
 }
 
 func (as *ActionSuite) Test_TextsResource_Update() {
-	as.Fail("Not Implemented!")
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	other := &models.User{}
+	as.NoError(as.DB.Create(other))
+	published := nulls.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	text := &models.Text{Title: "Kumano", Content: "Kodo", AuthorID: author.ID, PublishedAt: published}
+	as.NoError(as.DB.Create(text))
+	as.Session.Set("current_user_id", author.ID)
+
+	res := as.HTML("/texts/%s", text.ID).Put(map[string]interface{}{
+		"Title":       "Kumano Kodo",
+		"Content":     "A pilgrimage",
+		"Draft":       "false",
+		"AuthorID":    other.ID.String(),
+		"PublishedAt": "2001-01-01T00:00:00Z",
+		"Hidden":      "true",
+	})
+	as.Equal(302, res.Code)
+
+	as.NoError(as.DB.Reload(text))
+	as.Equal("Kumano Kodo", text.Title)
+	as.Equal("A pilgrimage", text.Content)
+	// privileged fields can't be forged through the form
+	as.Equal(author.ID, text.AuthorID)
+	as.WithinDuration(published.Time, text.PublishedAt.Time, time.Second)
+	as.False(text.Hidden)
+}
+
+func (as *ActionSuite) Test_TextsResource_Update_Republish() {
+	author := &models.User{}
+	as.NoError(as.DB.Create(author))
+	as.Session.Set("current_user_id", author.ID)
+
+	// at quota, with one of the texts unpublished since
+	var text *models.Text
+	for i := 0; i < 10; i++ {
+		ok, _, err := author.CanPost(as.DB)
+		as.NoError(err)
+		if !ok {
+			break
+		}
+		text = &models.Text{Title: "Posted", Content: "Steps", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+		as.NoError(as.DB.Create(text))
+		as.NoError(models.TextWentLive(as.DB, author, text))
+	}
+	res := as.HTML("/texts/%s", text.ID).Put(map[string]interface{}{"Title": "Posted", "Content": "Steps", "Draft": "true"})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(author))
+	score := author.Score
+
+	// republishing it was already paid for
+	res = as.HTML("/texts/%s", text.ID).Put(map[string]interface{}{"Title": "Posted", "Content": "Steps", "Draft": "false"})
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(text))
+	as.False(text.Draft)
+	as.NoError(as.DB.Reload(author))
+	as.Equal(score, author.Score)
 }
 
 func (as *ActionSuite) Test_TextsResource_Destroy() {
@@ -0,0 +1,50 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/plush"
+	"github.com/pkg/errors"
+)
+
+// AppURL is the public root of the site, e.g. "https://kumano.io",
+// every absolute link (emails, OAuth callbacks, feeds, canonical links) starts with it.
+// Set APP_URL to change it, it is required in production.
+var AppURL, appURLErr = parseAppURL(ENV, envy.Get("APP_URL", ""))
+
+// parseAppURL checks the configured root URL and normalizes it without a trailing slash.
+// Outside production it defaults to the local dev server.
+func parseAppURL(env, raw string) (string, error) {
+	if raw == "" {
+		if env == "production" {
+			return "", errors.New("APP_URL is required in production")
+		}
+		raw = "http://127.0.0.1:" + envy.Get("PORT", "3000")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid APP_URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("invalid APP_URL %q, it should look like https://example.com", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.Errorf("invalid APP_URL %q, it can't have a query or fragment", raw)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// AbsoluteURL turns a path of the app into a full URL under AppURL
+func AbsoluteURL(path string, args ...interface{}) string {
+	return AppURL + fmt.Sprintf(path, args...)
+}
+
+// canonicalURL is the absolute URL of the page being rendered, without its query
+func canonicalURL(help plush.HelperContext) string {
+	path, _ := help.Value("current_path").(string)
+	return AbsoluteURL("%s", path)
+}
@@ -0,0 +1,25 @@
+package actions
+
+func (as *ActionSuite) Test_ParseAppURL() {
+	u, err := parseAppURL("production", "https://kumano.io/")
+	as.NoError(err)
+	as.Equal("https://kumano.io", u)
+
+	// required in production only
+	_, err = parseAppURL("production", "")
+	as.Error(err)
+	u, err = parseAppURL("development", "")
+	as.NoError(err)
+	as.Contains(u, "http://127.0.0.1:")
+
+	for _, bad := range []string{"kumano.io", "ftp://kumano.io", "https://kumano.io/?a=b", "https://"} {
+		_, err = parseAppURL("development", bad)
+		as.Error(err, bad)
+	}
+}
+
+func (as *ActionSuite) Test_CanonicalURL() {
+	res := as.HTML("/search").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), `<link rel="canonical" href="`+AbsoluteURL("/search")+`">`)
+}
@@ -1,13 +1,14 @@
 package actions
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/uuid"
-	"github.com/nicomo/kumano/mailers"
 	"github.com/nicomo/kumano/models"
 	"github.com/pkg/errors"
 )
@@ -29,8 +30,9 @@ type UsersResource struct {
 	buffalo.Resource
 }
 
-// List gets all Users. This function is mapped to the path
-// GET /users
+// List is the directory of users. Param "sort" orders it (see models.DirectorySorts),
+// "provider" and "sponsor" (a user id) filter it, "page" and "per_page" paginate it.
+// This function is mapped to the path GET /users
 func (v UsersResource) List(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -38,21 +40,68 @@ func (v UsersResource) List(c buffalo.Context) error {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
 
-	users := &models.Users{}
+	filter := models.DirectoryFilter{
+		Sort:     c.Param("sort"),
+		Provider: c.Param("provider"),
+	}
+	if !contains(models.DirectorySorts, filter.Sort) {
+		filter.Sort = models.SortByScore
+	}
+	if !contains(providers, filter.Provider) {
+		filter.Provider = ""
+	}
 
-	// Paginate results. Params "page" and "per_page" control pagination.
-	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params())
+	// the sponsor whose godchildren we're listing
+	sponsor := &models.User{}
+	if id, err := uuid.FromString(c.Param("sponsor")); err == nil {
+		if err := tx.Find(sponsor, id); err != nil {
+			return c.Error(404, err)
+		}
+		filter.SponsorID = sponsor.ID
+	}
 
-	// Retrieve all Users from the DB
-	if err := q.All(users); err != nil {
+	p := pop.NewPaginatorFromParams(c.Params())
+	entries, pagination, err := models.Directory(tx, filter, p.Page, p.PerPage)
+	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	c.Set("entries", entries)
+	c.Set("filter", filter)
+	c.Set("sponsor", sponsor)
+	c.Set("by_sponsor", filter.SponsorID != uuid.Nil)
+	c.Set("sorts", models.DirectorySorts)
+	c.Set("providers", providers)
 	// Add the paginator to the context so it can be used in the template.
-	c.Set("pagination", q.Paginator)
+	c.Set("pagination", pagination)
 
-	return c.Render(200, r.Auto(c, users))
+	return c.Render(200, r.HTML("users/index.html"))
+}
+
+// Leaderboard shows the users who gained the most points this week, this month
+// or of all time, picked with param "period".
+// This function is mapped to the path GET /users/leaderboard
+func Leaderboard(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	period := c.Param("period")
+	if !contains(models.LeaderboardPeriods, period) {
+		period = models.LeaderboardWeek
+	}
+
+	entries, err := models.Leaderboard(tx, period, time.Now(), 20)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Set("entries", entries)
+	c.Set("period", period)
+	c.Set("periods", models.LeaderboardPeriods)
+	return c.Render(200, r.HTML("users/leaderboard.html"))
 }
 
 // Show gets the data for one User. This function is mapped to
@@ -73,11 +122,58 @@ func (v UsersResource) Show(c buffalo.Context) error {
 	}
 
 	// Is the user looking at own profile?
-	if c.Session().Get("current_user_id") != nil {
-		c.Set("self", c.Session().Get("current_user_id").(uuid.UUID).String() == c.Param("user_id"))
-	} else {
-		c.Set("self", false)
+	self := false
+	if cu, ok := c.Value("current_user").(*models.User); ok {
+		self = cu.ID == user.ID
 	}
+	c.Set("self", self)
+
+	// follow graph
+	followers, err := models.CountFollowers(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	following, err := models.CountFollowing(tx, user.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	followed := false
+	if cu, ok := c.Value("current_user").(*models.User); ok && !self {
+		if followed, err = models.IsFollowing(tx, cu.ID, user.ID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	c.Set("followers_count", followers)
+	c.Set("following_count", following)
+	c.Set("followed", followed)
+
+	// her API tokens and the link to her starred feed, only for her eyes
+	tokens := models.APITokens{}
+	starredFeed := ""
+	if self {
+		if err := tx.Where("user_id = ?", user.ID).Order("created_at desc").All(&tokens); err != nil {
+			return errors.WithStack(err)
+		}
+		if starredFeed, err = StarredFeedURL(user.ID, feedAtom); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	c.Set("api_tokens", tokens)
+	c.Set("starred_feed_url", starredFeed)
+	c.Set("feed_path", fmt.Sprintf("/feeds/users/%s", user.ID))
+
+	// how she got her score, for her and admins
+	events := models.ScoreEvents{}
+	c.Set("score_pagination", pop.NewPaginator(1, 20))
+	if cu, ok := c.Value("current_user").(*models.User); ok && cu.CanManage(user.ID) {
+		q := models.ScoreHistory(tx, user.ID, c.Params())
+		if err := q.All(&events); err != nil {
+			return errors.WithStack(err)
+		}
+		c.Set("score_pagination", q.Paginator)
+	}
+	c.Set("score_events", events)
+	c.Set("privileges", models.Privileges)
 
 	return c.Render(200, r.Auto(c, user))
 }
@@ -92,69 +188,48 @@ func (v UsersResource) New(c buffalo.Context) error {
 // path POST /users
 func (v UsersResource) Create(c buffalo.Context) error {
 
-	// redirect url
-	redirectURL := "users/" + c.Session().Get("current_user_id").(uuid.UUID).String()
-
-	// Allocate an empty User
-	user := &models.User{}
-
-	// Bind user to the html form elements
-	if err := c.Bind(user); err != nil {
-		return errors.WithStack(err)
-	}
-
-	// add invitation token and time + sponsor ID
-	invitationToken, err := uuid.NewV4()
-	if err != nil {
-		fmt.Printf("Couldn't create uuid for invitation token: %s", err)
-		return c.Redirect(422, redirectURL)
-	}
-	user.InvitationToken = invitationToken.String()
-	user.InvitedAt = time.Now()
-	user.SponsorID = c.Session().Get("current_user_id").(uuid.UUID)
-
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
 	if !ok {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
+	sponsor := c.Value("current_user").(*models.User)
+
+	// Bind user to the html form elements
+	form := invitationForm{}
+	if err := c.Bind(&form); err != nil {
+		return errors.WithStack(err)
+	}
+	user := &models.User{}
+	form.apply(user)
 
-	// Validate the data from the html form
-	// FIXME: check unique email
-	verrs, err := tx.ValidateAndCreate(user, "provider", "provider_id")
+	// create the invitation, consuming one of the sponsor's sponsorships
+	verrs, err := models.InviteUser(tx, sponsor, user)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
 	if verrs.HasAny() {
-		// Make the errors available inside the html template
-		c.Set("errors", verrs)
-		fmt.Printf("\nverrs: %v\n", verrs)
+		for _, msgs := range verrs.Errors {
+			for _, msg := range msgs {
+				c.Flash().Add("danger", msg)
+			}
+		}
 		// Redirect to sponsor profile
-		return c.Redirect(302, redirectURL)
+		return c.Redirect(302, "/users/%s", sponsor.ID)
 	}
 
 	// send email to invited user
-	sponsor := c.Value("current_user").(*models.User)
-
-	emailData := map[string]string{
-		"emailTo":         user.Email.String,
-		"invitationURL":   "http://127.0.0.1:3000/auth/invitation/" + user.InvitationToken,
-		"sponsorName":     sponsor.Name.String,
-		"sponsorNickname": sponsor.Nickname.String,
-		"sponsorID":       user.SponsorID.String(),
-	}
-
-	if err := mailers.SendInvitation(emailData); err != nil {
-		fmt.Print(err)
+	if err := sendInvitation(user, sponsor); err != nil {
+		c.Logger().Errorf("sending invitation to %s: %v", user.Email.String, err)
 		c.Flash().Add("danger", T.Translate(c, "users.sendinvitation.failure"))
 	} else {
 		// If there are no errors set a success message
 		c.Flash().Add("success", T.Translate(c, "users.sendinvitation.success"))
 	}
 
-	// and redirect to the users index page
-	return c.Redirect(302, redirectURL)
+	// and redirect to the pending invitations
+	return c.Redirect(302, "/invitations")
 }
 
 // Edit renders a edit form for a User. This function is
@@ -172,6 +247,7 @@ func (v UsersResource) Edit(c buffalo.Context) error {
 	if err := tx.Find(user, c.Param("user_id")); err != nil {
 		return c.Error(404, err)
 	}
+	c.Set("digest_frequencies", models.DigestFrequencies)
 
 	return c.Render(200, r.Auto(c, user))
 }
@@ -193,9 +269,11 @@ func (v UsersResource) Update(c buffalo.Context) error {
 	}
 
 	// Bind User to the html form elements
-	if err := c.Bind(user); err != nil {
+	form := profileForm{}
+	if err := c.Bind(&form); err != nil {
 		return errors.WithStack(err)
 	}
+	form.apply(user)
 
 	verrs, err := tx.ValidateAndUpdate(user)
 	if err != nil {
@@ -205,6 +283,7 @@ func (v UsersResource) Update(c buffalo.Context) error {
 	if verrs.HasAny() {
 		// Make the errors available inside the html template
 		c.Set("errors", verrs)
+		c.Set("digest_frequencies", models.DigestFrequencies)
 
 		// Render again the edit.html template that the user can
 		// correct the input.
@@ -218,7 +297,10 @@ func (v UsersResource) Update(c buffalo.Context) error {
 	return c.Render(200, r.Auto(c, user))
 }
 
-// Destroy deletes a User from the DB. This function is mapped
+// Destroy asks for a User to be deleted, with param "Mode" telling what happens
+// to her content (see models.DeletionModes). Nothing is deleted yet: the user
+// is sent to log in again with her provider, then AuthCallback schedules
+// the deletion, see confirmDeletion. This function is mapped
 // to the path DELETE /users/{user_id}
 func (v UsersResource) Destroy(c buffalo.Context) error {
 	// Get the DB connection from the context
@@ -226,6 +308,7 @@ func (v UsersResource) Destroy(c buffalo.Context) error {
 	if !ok {
 		return errors.WithStack(errors.New("no transaction found"))
 	}
+	cu := c.Value("current_user").(*models.User)
 
 	// Allocate an empty User
 	user := &models.User{}
@@ -234,25 +317,55 @@ func (v UsersResource) Destroy(c buffalo.Context) error {
 	if err := tx.Find(user, c.Param("user_id")); err != nil {
 		return c.Error(404, err)
 	}
+	if user.IsGhost() {
+		return c.Error(403, errors.New("the ghost user can't be deleted"))
+	}
 
-	if err := tx.Destroy(user); err != nil {
-		return errors.WithStack(err)
+	mode := c.Param("Mode")
+	if !contains(models.DeletionModes, mode) {
+		c.Flash().Add("danger", T.Translate(c, "user.deletion.mode.missing"))
+		return c.Redirect(302, "/users/%s/delete", user.ID)
 	}
 
-	// If there are no errors set a flash message
-	c.Flash().Add("success", T.Translate(c, "user.destroyed.success"))
+	c.Session().Set(sessionDeletionUser, user.ID.String())
+	c.Session().Set(sessionDeletionMode, mode)
+	c.Session().Set(sessionDeletionAt, time.Now().Unix())
+	if err := c.Session().Save(); err != nil {
+		return errors.WithStack(err)
+	}
 
-	// Redirect to the users index page
-	return c.Render(200, r.Auto(c, user))
+	return c.Redirect(302, "/auth/%s", cu.Provider.String)
 }
 
-// SetCurrentUser is a middleware that sets the user in the session
+// SetCurrentUser is a middleware that sets the user in the session.
+// Non-browser clients authenticate with an "Authorization: Bearer <token>"
+// header instead, see models.APIToken.
 func SetCurrentUser(next buffalo.Handler) buffalo.Handler {
 	return func(c buffalo.Context) error {
+		if token := bearerToken(c); token != "" {
+			tx := c.Value("tx").(*pop.Connection)
+			u, err := models.FindUserByAPIToken(tx, token)
+			if err != nil {
+				if errors.Cause(err) == sql.ErrNoRows || errors.Cause(err) == models.ErrAPITokenExpired {
+					return apiError(c, 401, "invalid or expired token")
+				}
+				return errors.WithStack(err)
+			}
+			c.Set("current_user", u)
+			c.Set("token_authenticated", true)
+			return next(c)
+		}
+
 		if uid := c.Session().Get("current_user_id"); uid != nil {
 			u := &models.User{}
 			tx := c.Value("tx").(*pop.Connection)
-			if err := tx.Find(u, uid); err != nil {
+			err := tx.Find(u, uid)
+			if errors.Cause(err) == sql.ErrNoRows {
+				// her account was deleted since she logged in
+				c.Session().Delete("current_user_id")
+				return next(c)
+			}
+			if err != nil {
 				return errors.WithStack(err)
 			}
 			c.Set("current_user", u)
@@ -261,11 +374,20 @@ func SetCurrentUser(next buffalo.Handler) buffalo.Handler {
 	}
 }
 
+// bearerToken returns the API token sent in the Authorization header, if any
+func bearerToken(c buffalo.Context) string {
+	header := c.Request().Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
 // AdminRequired middleware checks the user is logged in + an admin before accessing route.
 func AdminRequired(next buffalo.Handler) buffalo.Handler {
 	return func(c buffalo.Context) error {
 		u, ok := c.Value("current_user").(*models.User)
-		if ok && !u.IsAdmin {
+		if !ok || !u.IsAdmin {
 			c.Flash().Add("danger", "Only admins get to view this page. You're missing out, I'll tell you.")
 			return c.Redirect(302, "/")
 		}
@@ -299,3 +421,13 @@ func CanPost(user *models.User, next buffalo.Context) buffalo.Context {
 	return next
 }
 */
+
+// contains checks if s is one of the choices
+func contains(choices []string, s string) bool {
+	for _, c := range choices {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,60 @@
 package actions
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
 func (as *ActionSuite) Test_UsersResource_List() {
-	as.Fail("Not Implemented!")
+	sponsor := &models.User{Name: nulls.NewString("Sponsor"), ProviderID: nulls.NewString("1"), Provider: nulls.NewString("github")}
+	as.NoError(as.DB.Create(sponsor))
+	invited := &models.User{Name: nulls.NewString("Godchild"), ProviderID: nulls.NewString("2"), Provider: nulls.NewString("twitter"), SponsorID: sponsor.ID}
+	as.NoError(as.DB.Create(invited))
+	as.Session.Set("current_user_id", sponsor.ID)
+
+	res := as.HTML("/users?sort=texts").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Godchild")
+
+	res = as.HTML("/users?provider=github").Get()
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "Godchild")
+
+	res = as.HTML("/users?sponsor=%s", sponsor.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Godchild")
+}
+
+func (as *ActionSuite) Test_Leaderboard() {
+	user := &models.User{Name: nulls.NewString("Champion"), ProviderID: nulls.NewString("1")}
+	as.NoError(as.DB.Create(user))
+	as.NoError(models.CreditSignUp(as.DB, user))
+
+	res := as.HTML("/users/leaderboard?period=month").Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Champion")
 }
 
 func (as *ActionSuite) Test_UsersResource_Show() {
-	as.Fail("Not Implemented!")
+	user := &models.User{Name: nulls.NewString("Jane")}
+	stranger := &models.User{}
+	as.NoError(as.DB.Create(user))
+	as.NoError(as.DB.Create(stranger))
+	as.NoError(models.CreditSignUp(as.DB, user))
+
+	// the score history is hers only
+	as.Session.Set("current_user_id", user.ID)
+	res := as.HTML("/users/%s", user.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Joined Kumano")
+
+	as.Session.Set("current_user_id", stranger.ID)
+	res = as.HTML("/users/%s", user.ID).Get()
+	as.Equal(200, res.Code)
+	as.NotContains(res.Body.String(), "Joined Kumano")
 }
 
 func (as *ActionSuite) Test_UsersResource_New() {
@@ -13,7 +62,31 @@ func (as *ActionSuite) Test_UsersResource_New() {
 }
 
 func (as *ActionSuite) Test_UsersResource_Create() {
-	as.Fail("Not Implemented!")
+	sponsor := &models.User{Name: nulls.NewString("Sponsor"), SponsorshipsCount: 1}
+	as.NoError(as.DB.Create(sponsor))
+	as.Session.Set("current_user_id", sponsor.ID)
+
+	res := as.HTML("/users").Post(map[string]interface{}{"Email": "friend@example.com"})
+	as.Equal(302, res.Code)
+
+	invited := &models.User{}
+	as.NoError(as.DB.Where("email = ?", "friend@example.com").First(invited))
+	as.Equal(sponsor.ID, invited.SponsorID)
+
+	// the invitation went through the mail sender
+	as.Len(as.Mails(), 1)
+	m := as.Mails()[0]
+	as.Equal([]string{"friend@example.com"}, m.To)
+	as.Contains(m.Bodies[0].Content, invited.InvitationToken)
+
+	// the sponsorship was used up, no more invitations
+	as.NoError(as.DB.Reload(sponsor))
+	as.Equal(0, sponsor.SponsorshipsCount)
+	res = as.HTML("/users").Post(map[string]interface{}{"Email": "other@example.com"})
+	as.Equal(302, res.Code)
+	count, err := as.DB.Where("email = ?", "other@example.com").Count("users")
+	as.NoError(err)
+	as.Equal(0, count)
 }
 
 func (as *ActionSuite) Test_UsersResource_Edit() {
@@ -21,9 +94,85 @@ func (as *ActionSuite) Test_UsersResource_Edit() {
 }
 
 func (as *ActionSuite) Test_UsersResource_Update() {
-	as.Fail("Not Implemented!")
+	sponsor := &models.User{}
+	as.NoError(as.DB.Create(sponsor))
+	user := &models.User{
+		Name:       nulls.NewString("Jane"),
+		Nickname:   nulls.NewString("jane"),
+		AvatarURL:  nulls.NewString("https://example.com/jane.png"),
+		Provider:   nulls.NewString("github"),
+		ProviderID: nulls.NewString("42"),
+		Score:      10,
+		SponsorID:  sponsor.ID,
+	}
+	as.NoError(as.DB.Create(user))
+	as.Session.Set("current_user_id", user.ID)
+
+	res := as.HTML("/users/%s", user.ID).Put(map[string]interface{}{
+		"Name":              "Jane Doe",
+		"Nickname":          "jane",
+		"Bio":               "Walker",
+		"IsAdmin":           "true",
+		"Score":             "1000",
+		"SponsorshipsCount": "50",
+		"SponsorID":         user.ID.String(),
+		"InvitationToken":   "forged",
+	})
+	as.Equal(302, res.Code)
+
+	as.NoError(as.DB.Reload(user))
+	as.Equal("Jane Doe", user.Name.String)
+	as.Equal("Walker", user.Bio.String)
+	// privileged fields can't be forged through the form
+	as.False(user.IsAdmin)
+	as.Equal(10, user.Score)
+	as.Equal(0, user.SponsorshipsCount)
+	as.Equal(sponsor.ID, user.SponsorID)
+	as.Equal("", user.InvitationToken)
 }
 
 func (as *ActionSuite) Test_UsersResource_Destroy() {
-	as.Fail("Not Implemented!")
+	user := &models.User{Provider: nulls.NewString("github"), ProviderID: nulls.NewString("42")}
+	as.NoError(as.DB.Create(user))
+	as.Session.Set("current_user_id", user.ID)
+
+	res := as.HTML("/users/%s/delete", user.ID).Get()
+	as.Equal(200, res.Code)
+
+	// she has to choose what happens to her content
+	res = as.HTML("/users/%s", user.ID).Post(map[string]interface{}{"_method": "DELETE"})
+	as.Equal(302, res.Code)
+	as.Equal(fmt.Sprintf("/users/%s/delete", user.ID), res.Header().Get("Location"))
+
+	// then log in again before anything is scheduled
+	res = as.HTML("/users/%s", user.ID).Post(map[string]interface{}{"_method": "DELETE", "Mode": models.DeletionDeleteContent})
+	as.Equal(302, res.Code)
+	as.Equal("/auth/github", res.Header().Get("Location"))
+	as.NoError(as.DB.Reload(user))
+	as.False(user.DeletionScheduled())
+	as.NotNil(as.Session.Get(sessionDeletionAt))
+
+	// a failed login forgets the deletion
+	res = as.HTML("/auth/github/callback").Get()
+	as.Equal(401, res.Code)
+	as.Nil(as.Session.Get(sessionDeletionUser))
+	as.Nil(as.Session.Get(sessionDeletionAt))
+	as.NoError(as.DB.Reload(user))
+	as.False(user.DeletionScheduled())
+}
+
+func (as *ActionSuite) Test_DeletionCancel() {
+	user := &models.User{Provider: nulls.NewString("github"), ProviderID: nulls.NewString("42")}
+	as.NoError(as.DB.Create(user))
+	as.NoError(models.ScheduleDeletion(as.DB, user, models.DeletionAnonymizeContent, time.Now()))
+	as.Session.Set("current_user_id", user.ID)
+
+	res := as.HTML("/users/%s", user.ID).Get()
+	as.Equal(200, res.Code)
+	as.Contains(res.Body.String(), "Keep the account")
+
+	res = as.HTML("/users/%s/deletion", user.ID).Delete()
+	as.Equal(302, res.Code)
+	as.NoError(as.DB.Reload(user))
+	as.False(user.DeletionScheduled())
 }
@@ -10,16 +10,49 @@ $("#flag-text").hover(
     function(){ $(this).removeClass('btn-danger')}
 )
 
-$("#star-text").click(function(){
+// star / unstar toggle: POST stars, DELETE unstars,
+// both answer with the new status and star count
+$("#star-text").click(function(e){
+    e.preventDefault();
+    var btn = $(this);
     $.ajax({
-        type: 'POST',
-        url: '/texts/' + $('a[id="star-text"]').attr('data-star-textid') + '/star',
+        type: btn.attr('data-starred') === 'true' ? 'DELETE' : 'POST',
+        url: '/texts/' + btn.attr('data-star-textid') + '/star',
         headers: {'X-CSRF-TOKEN': $('meta[name="csrf-token"]').attr('content')},
-        success: function(){
-            $("#glyph-star").removeClass('glyphicon-star-empty').addClass('glyphicon-star')
+        dataType: 'json',
+        success: function(data){
+            btn.attr('data-starred', data.starred ? 'true' : 'false');
+            $("#star-count").text(data.count);
+            if (data.starred) {
+                $("#glyph-star").removeClass('glyphicon-star-empty').addClass('glyphicon-star');
+                $("#star-label").text('Unstar');
+            } else {
+                $("#glyph-star").removeClass('glyphicon-star').addClass('glyphicon-star-empty');
+                $("#star-label").text('Star');
+            }
         }
     });
-});    
+});
+// tag autocompletion: suggest tags like the one being typed,
+// keeping the tags already typed before it
+$("#Tags").on('input', function(){
+    var input = $(this);
+    var typed = input.val().split(',');
+    var term = typed.pop().trim();
+    var list = $("#tag-suggestions");
+    if (term === '') {
+        list.empty();
+        return;
+    }
+    $.getJSON('/tags/suggest', {q: term}, function(names){
+        var before = typed.map(function(t){ return t.trim(); }).filter(function(t){ return t !== ''; });
+        list.empty();
+        $.each(names, function(i, name){
+            list.append($('<option>').attr('value', before.concat([name]).join(', ')));
+        });
+    });
+});
+
 /*  done(function() {
         console.log("req done");
     });
@@ -0,0 +1,44 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+var _ = grift.Namespace("api", func() {
+
+	grift.Desc("token", "Creates an API token for a user: buffalo task api:token <user_id> [name]")
+	grift.Add("token", func(c *grift.Context) error {
+		if len(c.Args) < 1 {
+			return errors.New("usage: buffalo task api:token <user_id> [name]")
+		}
+		name := "cli"
+		if len(c.Args) > 1 {
+			name = c.Args[1]
+		}
+
+		u := &models.User{}
+		if err := models.DB.Find(u, c.Args[0]); err != nil {
+			return errors.WithStack(err)
+		}
+
+		t, plain, err := models.NewAPIToken(u.ID, name)
+		if err != nil {
+			return err
+		}
+		verrs, err := models.DB.ValidateAndCreate(t)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			return errors.New(verrs.Error())
+		}
+
+		fmt.Printf("API token for @%s (keep it somewhere safe, it won't be shown again):\n%s\n", u.Nickname.String, plain)
+		return nil
+	})
+
+})
@@ -0,0 +1,29 @@
+package grifts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/actions"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+var _ = grift.Namespace("digests", func() {
+
+	grift.Desc("send", "Emails the digests that are due: buffalo task digests:send daily|weekly, run it from cron daily")
+	grift.Add("send", func(c *grift.Context) error {
+		if len(c.Args) < 1 {
+			return errors.New("usage: buffalo task digests:send daily|weekly")
+		}
+		// each digest is committed on its own, see SendDigests
+		sent, err := actions.SendDigests(models.DB, c.Args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("sent %d %s digests\n", sent, c.Args[0])
+		return nil
+	})
+
+})
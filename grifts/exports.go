@@ -0,0 +1,26 @@
+package grifts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("exports", func() {
+
+	grift.Desc("prune", "Deletes the data exports past EXPORT_TTL and their archives, run it from cron daily")
+	grift.Add("prune", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.PruneExports(tx, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("pruned %d exports\n", count)
+			return nil
+		})
+	})
+
+})
@@ -0,0 +1,25 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("invitations", func() {
+
+	grift.Desc("expire", "Revokes the invitations older than INVITATION_TTL and refunds their sponsors, run it from cron daily")
+	grift.Add("expire", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.ExpireInvitations(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("expired %d invitations\n", count)
+			return nil
+		})
+	})
+
+})
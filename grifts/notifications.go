@@ -0,0 +1,25 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("notifications", func() {
+
+	grift.Desc("prune", "Deletes the notifications read more than NOTIFICATIONS_RETENTION ago, run it from cron daily")
+	grift.Add("prune", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.PruneNotifications(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("pruned %d notifications\n", count)
+			return nil
+		})
+	})
+
+})
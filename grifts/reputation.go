@@ -0,0 +1,25 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("reputation", func() {
+
+	grift.Desc("recompute", "Resets every user's score to the sum of her score history")
+	grift.Add("recompute", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.RecomputeScores(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("fixed %d scores\n", count)
+			return nil
+		})
+	})
+
+})
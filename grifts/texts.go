@@ -0,0 +1,27 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("texts", func() {
+
+	grift.Desc("publish", "Publishes scheduled texts whose time has come, run it from cron every minute or so")
+	grift.Add("publish", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			published, err := models.PublishDueTexts(tx)
+			if err != nil {
+				return err
+			}
+			for _, t := range published {
+				fmt.Printf("published %s %q\n", t.ID, t.Title)
+			}
+			return nil
+		})
+	})
+
+})
@@ -0,0 +1,26 @@
+package grifts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/nicomo/kumano/models"
+)
+
+var _ = grift.Namespace("users", func() {
+
+	grift.Desc("delete", "Deletes the accounts whose ACCOUNT_DELETION_GRACE is over, run it from cron daily")
+	grift.Add("delete", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.DeleteDueAccounts(tx, time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("deleted %d accounts\n", count)
+			return nil
+		})
+	})
+
+})
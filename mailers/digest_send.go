@@ -0,0 +1,61 @@
+package mailers
+
+import (
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// SendDigest sends a user the summary of what happened since her last digest.
+// appURL is the root of the site for links, unsubscribeURL turns digests off in one click.
+// Called from actions.SendDigests
+func SendDigest(digest *models.Digest, appURL, unsubscribeURL string) error {
+	m := mail.NewMessage()
+
+	m.Subject = "Your Kumano digest"
+	m.From = From
+	m.To = []string{digest.User.Email.String}
+	err := m.AddBody(r.HTML("digest_send.html"), render.Data{
+		"digest":         digest,
+		"appURL":         appURL,
+		"unsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return send(m, unsubscribeURL)
+}
+
+// SendNotification emails a user about a single notification.
+// Called from actions notify
+func SendNotification(to models.User, subject string, n *models.Notification, appURL, unsubscribeURL string) error {
+	m := mail.NewMessage()
+
+	m.Subject = subject
+	m.From = From
+	m.To = []string{to.Email.String}
+	err := m.AddBody(r.HTML("notification_send.html"), render.Data{
+		"user":           to,
+		"notification":   n,
+		"appURL":         appURL,
+		"unsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return send(m, unsubscribeURL)
+}
+
+// send adds the one-click unsubscribe headers (RFC 8058) and sends the message
+func send(m mail.Message, unsubscribeURL string) error {
+	m.Headers["List-Unsubscribe"] = "<" + unsubscribeURL + ">"
+	m.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+
+	if err := Sender.Send(m); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package mailers
+
+import (
+	"time"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/nicomo/kumano/models"
+	"github.com/pkg/errors"
+)
+
+// SendExportReady tells a user the export of her data is ready to download.
+// Called from actions buildExportJob, once a large export is built.
+func SendExportReady(to models.User, downloadURL string, expiresAt time.Time) error {
+	m := mail.NewMessage()
+
+	m.Subject = "Your Kumano data is ready to download"
+	m.From = From
+	m.To = []string{to.Email.String}
+	err := m.AddBody(r.HTML("export_ready.html"), render.Data{
+		"user":        to,
+		"downloadURL": downloadURL,
+		"expiresAt":   expiresAt.Format("Jan 2, 2006 15:04 MST"),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(Sender.Send(m))
+}
@@ -0,0 +1,172 @@
+package mailers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/pkg/errors"
+)
+
+// FileSender writes each message as an .eml file in Dir instead of sending it,
+// open them with any mail client to check what would have been sent
+type FileSender struct {
+	Dir string
+}
+
+// NewFileSender makes sure dir exists
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &FileSender{Dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// Send writes the message to a file named after the time and subject
+func (s *FileSender) Send(m mail.Message) error {
+	b, err := encodeMessage(m)
+	if err != nil {
+		return err
+	}
+
+	subject := strings.Trim(unsafeFileChars.ReplaceAllString(m.Subject, "-"), "-")
+	if len(subject) > 40 {
+		subject = subject[:40]
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), subject)
+
+	err = ioutil.WriteFile(filepath.Join(s.Dir, name), b, 0644)
+	return errors.WithStack(err)
+}
+
+// encodeMessage renders the message in the RFC 5322 format, bodies as
+// multipart/alternative and attachments, if any, in a multipart/mixed around them
+func encodeMessage(m mail.Message) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	header := func(k, v string) {
+		fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	if len(m.CC) > 0 {
+		header("Cc", strings.Join(m.CC, ", "))
+	}
+	if len(m.Bcc) > 0 {
+		header("Bcc", strings.Join(m.Bcc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(k, m.Headers[k])
+	}
+	header("MIME-Version", "1.0")
+
+	mixed := multipart.NewWriter(buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+
+	// the bodies, alternative versions of the same content
+	alt := &bytes.Buffer{}
+	altw := multipart.NewWriter(alt)
+	for _, body := range m.Bodies {
+		pw, err := altw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body.ContentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(body.Content)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := altw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pw, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + altw.Boundary()},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := pw.Write(alt.Bytes()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, a := range m.Attachments {
+		pw, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, &lineWrapper{w: pw})
+		if _, err := io.Copy(enc, a.Reader); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// lineWrapper breaks base64 output into 76 characters lines, as MIME wants
+type lineWrapper struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := 76 - l.col
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+		if l.col == 76 {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,111 @@
+package mailers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bmail "github.com/gobuffalo/buffalo/mail"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicomo/kumano/mailers"
+)
+
+func Test_FileSender_RoundTrip(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "mails")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sender, err := mailers.NewFileSender(dir)
+	r.NoError(err)
+
+	// long enough for the base64 to be wrapped on several lines
+	attachment := bytes.Repeat([]byte("kumano\x00\xff"), 40)
+	html := "<p>Café au lait, une très longue ligne qui dépasse les soixante-seize caractères autorisés par ligne</p>"
+
+	m := bmail.NewMessage()
+	m.From = "kumano@example.com"
+	m.To = []string{"ana@example.com", "bo@example.com"}
+	m.Subject = "Bienvenue à Kumano"
+	m.Headers["List-Unsubscribe"] = "<https://kumano.io/unsubscribe>"
+	m.Bodies = []bmail.Body{
+		{ContentType: "text/html", Content: html},
+		{ContentType: "text/plain", Content: "Café = coffee"},
+	}
+	m.Attachments = []bmail.Attachment{
+		{Name: "export.zip", ContentType: "application/zip", Reader: bytes.NewReader(attachment)},
+	}
+	r.NoError(sender.Send(m))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	r.NoError(err)
+	r.Len(files, 1)
+	r.True(strings.HasSuffix(files[0], "-Bienvenue-Kumano.eml"), files[0])
+
+	f, err := os.Open(files[0])
+	r.NoError(err)
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	r.NoError(err)
+	r.Equal("kumano@example.com", msg.Header.Get("From"))
+	r.Equal("ana@example.com, bo@example.com", msg.Header.Get("To"))
+	r.Equal("<https://kumano.io/unsubscribe>", msg.Header.Get("List-Unsubscribe"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	r.NoError(err)
+	r.Equal(m.Subject, subject)
+	_, err = msg.Header.Date()
+	r.NoError(err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	r.NoError(err)
+	r.Equal("multipart/mixed", mediaType)
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	// the bodies come first, as alternatives
+	part, err := mixed.NextPart()
+	r.NoError(err)
+	mediaType, params, err = mime.ParseMediaType(part.Header.Get("Content-Type"))
+	r.NoError(err)
+	r.Equal("multipart/alternative", mediaType)
+	alt := multipart.NewReader(part, params["boundary"])
+	for _, body := range m.Bodies {
+		p, err := alt.NextPart()
+		r.NoError(err)
+		r.Equal(body.ContentType+"; charset=UTF-8", p.Header.Get("Content-Type"))
+		// NextPart decodes quoted-printable bodies and hides their encoding header
+		content, err := ioutil.ReadAll(p)
+		r.NoError(err)
+		r.Equal(body.Content, string(content))
+	}
+	_, err = alt.NextPart()
+	r.Equal(io.EOF, err)
+
+	// then the attachment
+	part, err = mixed.NextPart()
+	r.NoError(err)
+	r.Equal("application/zip", part.Header.Get("Content-Type"))
+	r.Equal("export.zip", part.FileName())
+	r.Equal("base64", part.Header.Get("Content-Transfer-Encoding"))
+	raw, err := ioutil.ReadAll(part)
+	r.NoError(err)
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\r\n") {
+		r.True(len(line) <= 76, "line too long: %q", line)
+	}
+	content, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)))
+	r.NoError(err)
+	r.Equal(attachment, content)
+
+	_, err = mixed.NextPart()
+	r.Equal(io.EOF, err)
+}
@@ -14,19 +14,20 @@ func SendInvitation(data map[string]string) error {
 
 	// fill in with your stuff:
 	m.Subject = "Invitation to Kumano"
-	m.From = "nicolas.kumanoio@gmail.com"
+	m.From = From
 	m.To = []string{data["emailTo"]}
 	err := m.AddBody(r.HTML("invitation_send.html"), render.Data{
-		"sponsorID":       data["sponsorID"],
+		"sponsorURL":      data["sponsorURL"],
 		"invitationURL":   data["invitationURL"],
 		"sponsorName":     data["sponsorName"],
-		"sponsorNickname": data["sponsor.Nickname"],
+		"sponsorNickname": data["sponsorNickname"],
+		"expiresAt":       data["expiresAt"],
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	err = smtp.Send(m)
+	err = Sender.Send(m)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -7,37 +7,82 @@ import (
 	"github.com/gobuffalo/buffalo/render"
 	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/packr"
+	"github.com/pkg/errors"
 )
 
-var smtp mail.Sender
+// Sender delivers the mails, picked with MAIL_SENDER:
+//   - "smtp": SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, implicit TLS if SMTP_SSL=true (e.g. port 465),
+//     otherwise STARTTLS when the server offers it, plain text when it doesn't
+//   - "starttls": same settings, but STARTTLS is required (e.g. port 587), see StartTLSSender
+//   - "file": writes each mail as an .eml file in MAIL_DIR (default tmp/mails)
+//   - "memory": keeps the mails in a MemorySender, for tests
+//
+// Defaults to "smtp" in production, "memory" in test and "file" otherwise.
+var Sender mail.Sender
 var r *render.Engine
 
+// From is the address mails are sent from, set MAIL_FROM to change it
+var From = envy.Get("MAIL_FROM", "nicolas.kumanoio@gmail.com")
+
 func init() {
+	kind := envy.Get("MAIL_SENDER", defaultSender(envy.Get("GO_ENV", "development")))
+
+	var err error
+	if Sender, err = NewSender(kind); err != nil {
+		// don't take the whole app down, sending mails will report the error
+		log.Printf("mailers: %v", err)
+		Sender = brokenSender{err}
+	}
+
+	r = render.New(render.Options{
+		HTMLLayout:   "layout.html",
+		TemplatesBox: packr.NewBox("../templates/mail"),
+		Helpers:      render.Helpers{},
+	})
+}
 
+func defaultSender(env string) string {
+	switch env {
+	case "production":
+		return "smtp"
+	case "test":
+		return "memory"
+	}
+	return "file"
+}
+
+// NewSender builds the sender of the given kind from the env, see Sender
+func NewSender(kind string) (mail.Sender, error) {
 	// Pulling config from the env.
 	port := envy.Get("SMTP_PORT", "1025")
 	host := envy.Get("SMTP_HOST", "localhost")
 	user := envy.Get("SMTP_USER", "")
 	password := envy.Get("SMTP_PASSWORD", "")
 
-	var err error
-	sender, err := mail.NewSMTPSender(host, port, user, password)
-
-	// FIXME: switch to TLS/SSL
-	// see https://support.google.com/accounts/answer/6010255
-	// https://gobuffalo.io/en/docs/mail/
-	// port 587 with TLS
-	// sender.Dialer.TLSConfig = &tls.Config{...}
-	sender.Dialer.SSL = true
-	if err != nil {
-		log.Fatal(err)
+	switch kind {
+	case "smtp":
+		sender, err := mail.NewSMTPSender(host, port, user, password)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// without SSL, the dialer still upgrades with STARTTLS if the server offers it
+		sender.Dialer.SSL = envy.Get("SMTP_SSL", "false") == "true"
+		return sender, nil
+	case "starttls":
+		return NewStartTLSSender(host, port, user, password), nil
+	case "file":
+		return NewFileSender(envy.Get("MAIL_DIR", "tmp/mails"))
+	case "memory":
+		return &MemorySender{}, nil
 	}
+	return nil, errors.Errorf("unknown MAIL_SENDER %q, use smtp, starttls, file or memory", kind)
+}
 
-	smtp = sender
+// brokenSender stands in for a sender that couldn't be configured
+type brokenSender struct {
+	err error
+}
 
-	r = render.New(render.Options{
-		HTMLLayout:   "layout.html",
-		TemplatesBox: packr.NewBox("../templates/mail"),
-		Helpers:      render.Helpers{},
-	})
+func (s brokenSender) Send(m mail.Message) error {
+	return errors.Wrap(s.err, "mail sender is not configured")
 }
@@ -0,0 +1,101 @@
+package mailers_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/gobuffalo/envy"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicomo/kumano/mailers"
+)
+
+func Test_NewSender(t *testing.T) {
+	r := require.New(t)
+	envy.Set("SMTP_HOST", "mail.example.com")
+	envy.Set("SMTP_PORT", "587")
+	defer envy.Set("SMTP_HOST", "localhost")
+	defer envy.Set("SMTP_PORT", "1025")
+
+	s, err := mailers.NewSender("smtp")
+	r.NoError(err)
+	r.IsType(&mail.SMTPSender{}, s)
+
+	s, err = mailers.NewSender("starttls")
+	r.NoError(err)
+	r.IsType(&mailers.StartTLSSender{}, s)
+	starttls := s.(*mailers.StartTLSSender)
+	r.Equal("mail.example.com", starttls.Host)
+	r.Equal("587", starttls.Port)
+	r.Equal("mail.example.com", starttls.TLSConfig.ServerName)
+	r.False(starttls.TLSConfig.InsecureSkipVerify)
+
+	s, err = mailers.NewSender("memory")
+	r.NoError(err)
+	r.IsType(&mailers.MemorySender{}, s)
+
+	_, err = mailers.NewSender("pigeon")
+	r.Error(err)
+}
+
+func Test_StartTLSSender_RequiresStartTLS(t *testing.T) {
+	r := require.New(t)
+
+	// a server that doesn't offer STARTTLS and records what it's told
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	defer l.Close()
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			commands <- nil
+			return
+		}
+		defer conn.Close()
+		received := []string{}
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(s string) {
+			rw.WriteString(s + "\r\n")
+			rw.Flush()
+		}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				break
+			}
+			cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+			received = append(received, cmd)
+			switch cmd {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case "QUIT":
+				reply("221 bye")
+			default:
+				reply("250 ok")
+			}
+		}
+		commands <- received
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	r.NoError(err)
+	m := mail.NewMessage()
+	m.From = "kumano@example.com"
+	m.To = []string{"ana@example.com"}
+	m.Subject = "Secret"
+	m.Bodies = []mail.Body{{ContentType: "text/plain", Content: "Don't send me in the clear"}}
+
+	err = mailers.NewStartTLSSender(host, port, "", "").Send(m)
+	r.Error(err)
+	r.Contains(err.Error(), "STARTTLS")
+
+	received := <-commands
+	r.NotContains(received, "MAIL")
+	r.NotContains(received, "DATA")
+}
@@ -0,0 +1,38 @@
+package mailers
+
+import (
+	"sync"
+
+	"github.com/gobuffalo/buffalo/mail"
+)
+
+// MemorySender keeps the messages instead of sending them.
+// It's the default in test, where tests can look at what was sent:
+//
+//	sent := mailers.Sender.(*mailers.MemorySender).Messages()
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+// Send records the message
+func (s *MemorySender) Send(m mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (s *MemorySender) Messages() []mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]mail.Message{}, s.messages...)
+}
+
+// Reset forgets the messages sent so far
+func (s *MemorySender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
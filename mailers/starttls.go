@@ -0,0 +1,97 @@
+package mailers
+
+import (
+	"crypto/tls"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+
+	"github.com/gobuffalo/buffalo/mail"
+	"github.com/pkg/errors"
+)
+
+// StartTLSSender sends mails over SMTP once the connection is upgraded with
+// STARTTLS. Unlike the "smtp" sender, it sends nothing to a server that doesn't offer it.
+type StartTLSSender struct {
+	Host      string
+	Port      string
+	User      string
+	Password  string
+	TLSConfig *tls.Config
+}
+
+// NewStartTLSSender checks the server's certificate against host
+func NewStartTLSSender(host, port, user, password string) *StartTLSSender {
+	return &StartTLSSender{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		TLSConfig: &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+}
+
+// Send delivers the message, failing if the server doesn't offer STARTTLS
+func (s *StartTLSSender) Send(m mail.Message) error {
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rcpts := []string{}
+	for _, list := range [][]string{m.To, m.CC, m.Bcc} {
+		for _, a := range list {
+			addr, err := netmail.ParseAddress(a)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	// Bcc recipients get the mail without being listed in it
+	m.Bcc = nil
+	b, err := encodeMessage(m)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.Dial(net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		return errors.Errorf("%s doesn't offer STARTTLS, not sending in the clear", s.Host)
+	}
+	if err := c.StartTLS(s.TLSConfig); err != nil {
+		return errors.WithStack(err)
+	}
+	if s.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.User, s.Password, s.Host)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(c.Quit())
+}
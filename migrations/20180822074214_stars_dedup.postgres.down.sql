@@ -0,0 +1 @@
+-- the duplicate stars are gone for good
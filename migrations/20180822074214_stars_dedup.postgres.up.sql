@@ -0,0 +1,5 @@
+-- stars become unique per user and text in stars_unique,
+-- only the first star of each pair is kept
+DELETE FROM stars a USING stars b
+WHERE a.user_id = b.user_id AND a.text_id = b.text_id
+AND (a.created_at, a.id) > (b.created_at, b.id);
@@ -0,0 +1 @@
+-- the backfilled revisions go with the table, see create_text_revisions
@@ -0,0 +1,6 @@
+-- the current version of existing texts is their first revision,
+-- texts that already have one are left alone
+INSERT INTO text_revisions (id, text_id, author_id, title, content, created_at, updated_at)
+SELECT md5(random()::text || id::text)::uuid, id, author_id, title, content, updated_at, updated_at
+FROM texts
+WHERE NOT EXISTS (SELECT 1 FROM text_revisions WHERE text_revisions.text_id = texts.id);
@@ -0,0 +1,6 @@
+DROP TRIGGER users_search_vector_trigger ON users;
+DROP TRIGGER texts_search_vector_trigger ON texts;
+DROP FUNCTION users_search_vector_update();
+DROP FUNCTION texts_search_vector_update();
+ALTER TABLE users DROP COLUMN search_vector;
+ALTER TABLE texts DROP COLUMN search_vector;
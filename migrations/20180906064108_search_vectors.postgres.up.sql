@@ -0,0 +1,37 @@
+-- full-text search over texts (title, content) and users (name, nickname, bio)
+-- kept up to date by triggers, see models/search.go
+
+ALTER TABLE texts ADD COLUMN search_vector tsvector;
+ALTER TABLE users ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION texts_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.content, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.nickname, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.bio, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER texts_search_vector_trigger BEFORE INSERT OR UPDATE OF title, content ON texts
+  FOR EACH ROW EXECUTE PROCEDURE texts_search_vector_update();
+
+CREATE TRIGGER users_search_vector_trigger BEFORE INSERT OR UPDATE OF name, nickname, bio ON users
+  FOR EACH ROW EXECUTE PROCEDURE users_search_vector_update();
+
+-- fire the triggers for existing rows
+UPDATE texts SET title = title;
+UPDATE users SET name = name;
+
+CREATE INDEX texts_search_vector_idx ON texts USING gin(search_vector);
+CREATE INDEX users_search_vector_idx ON users USING gin(search_vector);
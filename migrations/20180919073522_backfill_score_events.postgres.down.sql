@@ -0,0 +1 @@
+-- the backfilled opening balances go with the table, see create_score_events
@@ -0,0 +1,7 @@
+-- existing scores are opening balances of the ledger,
+-- users who already have one are left alone
+INSERT INTO score_events (id, user_id, points, reason, source_type, created_at, updated_at)
+SELECT md5(random()::text || id::text)::uuid, id, score, 'opening_balance', '', now(), now()
+FROM users
+WHERE score <> 0
+AND NOT EXISTS (SELECT 1 FROM score_events WHERE score_events.user_id = users.id AND score_events.reason = 'opening_balance');
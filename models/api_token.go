@@ -0,0 +1,119 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// APIToken lets scripts and non-browser clients authenticate as a user
+// with an "Authorization: Bearer <token>" header.
+// Only a hash of the token is stored, the token itself is shown once on creation.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	ExpiresAt  nulls.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt nulls.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// ErrAPITokenExpired is returned when looking up a token past its expiry date
+var ErrAPITokenExpired = errors.New("api token expired")
+
+// TableName overrides the table name pop would derive from APIToken
+func (t APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// String is not required by pop and may be deleted
+func (t APIToken) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// APITokens is not required by pop and may be deleted
+type APITokens []APIToken
+
+// TableName overrides the table name pop would derive from APITokens
+func (t APITokens) TableName() string {
+	return "api_tokens"
+}
+
+// String is not required by pop and may be deleted
+func (t APITokens) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (t *APIToken) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: t.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: t.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: t.Name, Name: "Name", Max: 50, Message: "Keep the token name under 50 characters."},
+		&validators.StringIsPresent{Field: t.TokenHash, Name: "TokenHash"},
+	), nil
+}
+
+// Expired checks if the token is past its expiry date, tokens without one never expire
+func (t APIToken) Expired() bool {
+	return t.ExpiresAt.Valid && t.ExpiresAt.Time.Before(time.Now())
+}
+
+// NewAPIToken prepares a token for the user, the returned string
+// is the token to give the user, it can't be retrieved later on
+func NewAPIToken(userID uuid.UUID, name string) (*APIToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	plain := hex.EncodeToString(b)
+
+	t := &APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: HashAPIToken(plain),
+	}
+	return t, plain, nil
+}
+
+// HashAPIToken is how tokens are stored and looked up in the DB
+func HashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindUserByAPIToken retrieves the owner of a token and records
+// the token was just used. Expired tokens return ErrAPITokenExpired.
+func FindUserByAPIToken(tx *pop.Connection, plain string) (*User, error) {
+	t := &APIToken{}
+	if err := tx.Where("token_hash = ?", HashAPIToken(plain)).First(t); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if t.Expired() {
+		return nil, ErrAPITokenExpired
+	}
+
+	err := tx.RawQuery("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", time.Now(), t.ID).Exec()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	u := &User{}
+	if err := tx.Find(u, t.UserID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return u, nil
+}
@@ -0,0 +1,41 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_APIToken_FindUser() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+
+	t, plain, err := models.NewAPIToken(u.ID, "script")
+	ms.NoError(err)
+	ms.NotEqual(plain, t.TokenHash)
+	ms.NoError(ms.DB.Create(t))
+
+	found, err := models.FindUserByAPIToken(ms.DB, plain)
+	ms.NoError(err)
+	ms.Equal(u.ID, found.ID)
+
+	ms.NoError(ms.DB.Reload(t))
+	ms.True(t.LastUsedAt.Valid)
+
+	_, err = models.FindUserByAPIToken(ms.DB, "not a token")
+	ms.Error(err)
+}
+
+func (ms *ModelSuite) Test_APIToken_Expired() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+
+	t, plain, err := models.NewAPIToken(u.ID, "old script")
+	ms.NoError(err)
+	t.ExpiresAt = nulls.NewTime(time.Now().Add(-time.Hour))
+	ms.NoError(ms.DB.Create(t))
+
+	_, err = models.FindUserByAPIToken(ms.DB, plain)
+	ms.Equal(models.ErrAPITokenExpired, err)
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// Comment is a reply to a text, or to another comment on the same text
+// when it has a ParentID. Content is markdown, like texts'.
+type Comment struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	TextID    uuid.UUID  `json:"text_id" db:"text_id"`
+	AuthorID  uuid.UUID  `json:"author_id" db:"author_id"`
+	Author    User       `belongs_to:"user" db:"-"`
+	ParentID  nulls.UUID `json:"parent_id" db:"parent_id"`
+	Content   string     `json:"content" db:"content"`
+	Deleted   bool       `json:"deleted" db:"deleted"`
+	Replies   Comments   `json:"replies" db:"-"`
+}
+
+// String is not required by pop and may be deleted
+func (c Comment) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Comments is not required by pop and may be deleted
+type Comments []Comment
+
+// String is not required by pop and may be deleted
+func (c Comments) String() string {
+	jc, _ := json.Marshal(c)
+	return string(jc)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (c *Comment) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	if c.Deleted {
+		return validate.NewErrors(), nil
+	}
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: c.TextID, Name: "TextID"},
+		&validators.UUIDIsPresent{Field: c.AuthorID, Name: "AuthorID"},
+		&validators.StringIsPresent{Field: c.Content, Name: "Content", Message: "Your comment is empty."},
+		&validators.StringLengthInRange{Field: c.Content, Name: "Content", Max: 5000, Message: "Keep your comment under 5000 characters."},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// No new comments on locked texts, and replies stay on the text of their parent.
+func (c *Comment) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+
+	text := &Text{}
+	if err := tx.Find(text, c.TextID); err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if text.CommentsLocked {
+		verrs.Add("comments", "Comments are locked on this text.")
+	}
+
+	if c.ParentID.Valid {
+		parent := &Comment{}
+		if err := tx.Find(parent, c.ParentID.UUID); err != nil || parent.TextID != c.TextID {
+			verrs.Add("parent_id", "You can only reply to a comment on the same text.")
+		}
+	}
+	return verrs, nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (c *Comment) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// PostComment saves the comment and gives its author PointsComments
+func PostComment(tx *pop.Connection, c *Comment) (*validate.Errors, error) {
+	verrs, err := tx.ValidateAndCreate(c)
+	if err != nil || verrs.HasAny() {
+		return verrs, errors.WithStack(err)
+	}
+	return verrs, addScore(tx, c.AuthorID, PointsComments, ReasonCommented, SourceComment, c.ID)
+}
+
+// DeleteComment removes the comment and takes back the PointsComments its author got.
+// Comments with replies are only blanked out, so the thread below them stays readable.
+func DeleteComment(tx *pop.Connection, c *Comment) error {
+	hasReplies, err := tx.Where("parent_id = ?", c.ID).Exists("comments")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if hasReplies {
+		c.Deleted = true
+		c.Content = ""
+		err = tx.Update(c)
+	} else {
+		err = tx.Destroy(c)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return addScore(tx, c.AuthorID, -PointsComments, ReasonCommentDeleted, SourceComment, c.ID)
+}
+
+// CommentThread retrieves the comments on a text as a tree: top level comments
+// oldest first, each with its Replies. Authors are loaded with a single query.
+func CommentThread(tx *pop.Connection, textID uuid.UUID) (Comments, error) {
+	all := Comments{}
+	if err := tx.Where("text_id = ?", textID).Order("created_at asc").All(&all); err != nil {
+		return all, errors.WithStack(err)
+	}
+
+	ids := make([]uuid.UUID, len(all))
+	for i, c := range all {
+		ids[i] = c.AuthorID
+	}
+	authors, err := usersByID(tx, ids)
+	if err != nil {
+		return all, err
+	}
+
+	known := map[uuid.UUID]bool{}
+	for _, c := range all {
+		known[c.ID] = true
+	}
+	children := map[uuid.UUID]Comments{}
+	roots := Comments{}
+	for _, c := range all {
+		c.Author = authors[c.AuthorID]
+		if c.ParentID.Valid && known[c.ParentID.UUID] {
+			children[c.ParentID.UUID] = append(children[c.ParentID.UUID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var attach func(Comments) Comments
+	attach = func(cs Comments) Comments {
+		for i := range cs {
+			cs[i].Replies = attach(children[cs[i].ID])
+		}
+		return cs
+	}
+	return attach(roots), nil
+}
@@ -0,0 +1,78 @@
+package models_test
+
+import (
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Comment_Thread() {
+	author := &models.User{}
+	commenter := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	ms.NoError(ms.DB.Create(commenter))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+
+	first := &models.Comment{TextID: text.ID, AuthorID: commenter.ID, Content: "First"}
+	verrs, err := models.PostComment(ms.DB, first)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	reply := &models.Comment{TextID: text.ID, AuthorID: author.ID, Content: "Reply", ParentID: nulls.NewUUID(first.ID)}
+	verrs, err = models.PostComment(ms.DB, reply)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	thread, err := models.CommentThread(ms.DB, text.ID)
+	ms.NoError(err)
+	ms.Len(thread, 1)
+	ms.Equal(first.ID, thread[0].ID)
+	ms.Len(thread[0].Replies, 1)
+	ms.Equal(reply.ID, thread[0].Replies[0].ID)
+	ms.Equal(author.ID, thread[0].Replies[0].Author.ID)
+
+	ms.NoError(ms.DB.Reload(commenter))
+	ms.Equal(models.PointsComments, commenter.Score)
+
+	// with a reply, the comment is only blanked out
+	ms.NoError(models.DeleteComment(ms.DB, first))
+	ms.NoError(ms.DB.Reload(first))
+	ms.True(first.Deleted)
+	ms.Equal("", first.Content)
+	ms.NoError(ms.DB.Reload(commenter))
+	ms.Equal(0, commenter.Score)
+
+	// without, it's gone
+	ms.NoError(models.DeleteComment(ms.DB, reply))
+	count, err := ms.DB.Where("id = ?", reply.ID).Count("comments")
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_Comment_Validation() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	text := &models.Text{Title: "Kumano", AuthorID: author.ID}
+	other := &models.Text{Title: "Other", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(ms.DB.Create(other))
+
+	elsewhere := &models.Comment{TextID: other.ID, AuthorID: author.ID, Content: "Elsewhere"}
+	_, err := models.PostComment(ms.DB, elsewhere)
+	ms.NoError(err)
+
+	// replies stay on the text of their parent
+	verrs, err := models.PostComment(ms.DB, &models.Comment{TextID: text.ID, AuthorID: author.ID, Content: "Reply", ParentID: nulls.NewUUID(elsewhere.ID)})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	// no new comments on locked texts
+	text.CommentsLocked = true
+	ms.NoError(ms.DB.Update(text))
+	verrs, err = models.PostComment(ms.DB, &models.Comment{TextID: text.ID, AuthorID: author.ID, Content: "Locked"})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(models.PointsComments, author.Score)
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/pkg/errors"
+)
+
+// what happens to the texts and comments of a deleted account
+const (
+	DeletionDeleteContent    = "delete"
+	DeletionAnonymizeContent = "anonymize"
+)
+
+// DeletionModes are the choices offered to a user deleting her account
+var DeletionModes = []string{DeletionAnonymizeContent, DeletionDeleteContent}
+
+// ghostProvider marks the ghost user, see GhostUser
+const ghostProvider = "ghost"
+
+// DeletionGrace is how long after asking for it an account is actually deleted,
+// the deletion can be cancelled in the meantime.
+// Defaults to 14 days, set ACCOUNT_DELETION_GRACE (e.g. "72h") to change it.
+var DeletionGrace = 14 * 24 * time.Hour
+
+func init() {
+	if d, err := time.ParseDuration(envy.Get("ACCOUNT_DELETION_GRACE", "336h")); err == nil && d >= 0 {
+		DeletionGrace = d
+	} else {
+		log.Printf("invalid ACCOUNT_DELETION_GRACE, using %s", DeletionGrace)
+	}
+}
+
+// DeletionScheduled checks if the user asked for her account to be deleted
+func (u User) DeletionScheduled() bool {
+	return u.DeletionDueAt.Valid
+}
+
+// IsGhost checks if the user is the ghost author of anonymized content
+func (u User) IsGhost() bool {
+	return u.Provider.String == ghostProvider
+}
+
+// ScheduleDeletion has the account deleted once DeletionGrace has passed,
+// its content being deleted or anonymized according to mode
+func ScheduleDeletion(tx *pop.Connection, u *User, mode string, at time.Time) error {
+	if mode != DeletionDeleteContent && mode != DeletionAnonymizeContent {
+		return errors.Errorf("unknown deletion mode %q", mode)
+	}
+	if u.IsGhost() {
+		return errors.New("the ghost user can't be deleted")
+	}
+	u.DeletionDueAt = nulls.NewTime(at.Add(DeletionGrace))
+	u.DeletionMode = mode
+	err := tx.RawQuery("UPDATE users SET deletion_due_at = ?, deletion_mode = ? WHERE id = ?", u.DeletionDueAt, u.DeletionMode, u.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// CancelDeletion keeps the account after all
+func CancelDeletion(tx *pop.Connection, u *User) error {
+	u.DeletionDueAt = nulls.Time{}
+	u.DeletionMode = ""
+	err := tx.RawQuery("UPDATE users SET deletion_due_at = NULL, deletion_mode = '' WHERE id = ?", u.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// GhostUser returns the author anonymized texts and comments are given to,
+// creating it the first time. It has no provider id, so nobody can log in as it
+// and it isn't listed among the members.
+func GhostUser(tx *pop.Connection) (*User, error) {
+	ghost := &User{}
+	exists, err := tx.Where("provider = ?", ghostProvider).Exists("users")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if exists {
+		err = tx.Where("provider = ?", ghostProvider).First(ghost)
+		return ghost, errors.WithStack(err)
+	}
+
+	// no nickname, they are unique and any could be taken
+	ghost = &User{
+		Name:     nulls.NewString("Deleted user"),
+		Provider: nulls.NewString(ghostProvider),
+	}
+	return ghost, errors.WithStack(tx.Create(ghost))
+}
+
+// DeleteAccount deletes the user for good. Her texts and comments are deleted
+// or given to the ghost user, as she chose in DeletionMode. Either way her stars,
+// follows, notifications, tokens, score history and exports go away, the flags
+// she raised or resolved stay for moderation under the ghost, her pending
+// invitations are deleted and the people she sponsored who signed up
+// are reparented to her own sponsor.
+func DeleteAccount(tx *pop.Connection, u *User) error {
+	if u.IsGhost() {
+		return errors.New("the ghost user can't be deleted")
+	}
+	ghost, err := GhostUser(tx)
+	if err != nil {
+		return err
+	}
+
+	// the statements are collected first, then run in order
+	type statement struct {
+		sql  string
+		args []interface{}
+	}
+	statements := []statement{}
+	exec := func(sql string, args ...interface{}) {
+		statements = append(statements, statement{sql, args})
+	}
+
+	if u.DeletionMode == DeletionDeleteContent {
+		texts := "SELECT id FROM texts WHERE author_id = ?"
+		exec("DELETE FROM text_tags WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM stars WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM flags WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM comments WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM text_revisions WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM notifications WHERE text_id IN ("+texts+")", u.ID)
+		exec("DELETE FROM texts WHERE author_id = ?", u.ID)
+		// like DeleteComment, comments with replies are only blanked out
+		exec("UPDATE comments SET deleted = ?, content = '', author_id = ? "+
+			"WHERE author_id = ? AND id IN (SELECT parent_id FROM comments WHERE parent_id IS NOT NULL)", true, ghost.ID, u.ID)
+		exec("DELETE FROM comments WHERE author_id = ?", u.ID)
+	} else {
+		exec("UPDATE texts SET author_id = ? WHERE author_id = ?", ghost.ID, u.ID)
+		exec("UPDATE comments SET author_id = ? WHERE author_id = ?", ghost.ID, u.ID)
+	}
+
+	exec("UPDATE text_revisions SET author_id = ? WHERE author_id = ?", ghost.ID, u.ID)
+	// flags are unique per user and text, the ghost keeps one per text
+	exec("DELETE FROM flags WHERE user_id = ? AND text_id IN (SELECT text_id FROM flags WHERE user_id = ?)", u.ID, ghost.ID)
+	exec("UPDATE flags SET user_id = ? WHERE user_id = ?", ghost.ID, u.ID)
+	exec("UPDATE flags SET resolved_by = ? WHERE resolved_by = ?", ghost.ID, u.ID)
+	exec("DELETE FROM stars WHERE user_id = ?", u.ID)
+	exec("DELETE FROM follows WHERE follower_id = ? OR followed_id = ?", u.ID, u.ID)
+	exec("DELETE FROM notifications WHERE user_id = ? OR actor_id = ?", u.ID, u.ID)
+	exec("DELETE FROM api_tokens WHERE user_id = ?", u.ID)
+	exec("DELETE FROM score_events WHERE user_id = ?", u.ID)
+	// her pending invitations go without refund, the sponsor being gone,
+	// only the people who signed up are given to her own sponsor
+	exec("DELETE FROM users WHERE sponsor_id = ? AND invitation_token <> '' AND provider_id IS NULL", u.ID)
+	exec("UPDATE users SET sponsor_id = ? WHERE sponsor_id = ? AND provider_id IS NOT NULL", u.SponsorID, u.ID)
+
+	for _, s := range statements {
+		if err := tx.RawQuery(s.sql, s.args...).Exec(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	exports := Exports{}
+	if err := tx.Where("user_id = ?", u.ID).All(&exports); err != nil {
+		return errors.WithStack(err)
+	}
+	for i := range exports {
+		if err := os.Remove(exports[i].Path()); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	if err := tx.RawQuery("DELETE FROM exports WHERE user_id = ?", u.ID).Exec(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(tx.Destroy(u))
+}
+
+// DeleteDueAccounts deletes the accounts whose grace period is over,
+// it returns how many were deleted
+func DeleteDueAccounts(tx *pop.Connection, now time.Time) (int, error) {
+	users := Users{}
+	if err := tx.Where("deletion_due_at <= ?", now).All(&users); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for i := range users {
+		if err := DeleteAccount(tx, &users[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
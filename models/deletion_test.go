@@ -0,0 +1,159 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_ScheduleDeletion() {
+	u := &models.User{ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(u))
+
+	ms.Error(models.ScheduleDeletion(ms.DB, u, "vanish", time.Now()))
+	ms.False(u.DeletionScheduled())
+
+	now := time.Now()
+	ms.NoError(models.ScheduleDeletion(ms.DB, u, models.DeletionAnonymizeContent, now))
+	ms.NoError(ms.DB.Reload(u))
+	ms.True(u.DeletionScheduled())
+	ms.WithinDuration(now.Add(models.DeletionGrace), u.DeletionDueAt.Time, time.Second)
+
+	// nothing is deleted before the grace period is over
+	count, err := models.DeleteDueAccounts(ms.DB, now)
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	ms.NoError(models.CancelDeletion(ms.DB, u))
+	ms.NoError(ms.DB.Reload(u))
+	ms.False(u.DeletionScheduled())
+	count, err = models.DeleteDueAccounts(ms.DB, now.Add(models.DeletionGrace+time.Hour))
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_DeleteAccount_Anonymize() {
+	sponsor := &models.User{ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(sponsor))
+	u := &models.User{ProviderID: nulls.NewString("2"), SponsorID: sponsor.ID}
+	ms.NoError(ms.DB.Create(u))
+	godchild := &models.User{ProviderID: nulls.NewString("3"), SponsorID: u.ID}
+	ms.NoError(ms.DB.Create(godchild))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "pending", InvitedAt: time.Now(), SponsorID: u.ID, SponsorshipUsed: true}
+	ms.NoError(ms.DB.Create(invitee))
+
+	text := &models.Text{Title: "Up the ridge", Content: "Some steps", AuthorID: u.ID}
+	ms.NoError(ms.DB.Create(text))
+	other := &models.Text{Title: "Down the valley", Content: "More steps", AuthorID: sponsor.ID}
+	ms.NoError(ms.DB.Create(other))
+	_, err := models.StarText(ms.DB, u.ID, other)
+	ms.NoError(err)
+	_, err = models.FollowUser(ms.DB, godchild.ID, u.ID)
+	ms.NoError(err)
+
+	ms.NoError(models.ScheduleDeletion(ms.DB, u, models.DeletionAnonymizeContent, time.Now().Add(-models.DeletionGrace-time.Hour)))
+	count, err := models.DeleteDueAccounts(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	exists, err := ms.DB.Where("id = ?", u.ID).Exists("users")
+	ms.NoError(err)
+	ms.False(exists)
+
+	// her text stays, under the ghost
+	ghost, err := models.GhostUser(ms.DB)
+	ms.NoError(err)
+	ms.True(ghost.IsGhost())
+	ms.NoError(ms.DB.Reload(text))
+	ms.Equal(ghost.ID, text.AuthorID)
+
+	// her stars and follows go, her godchild goes to her sponsor
+	stars, err := ms.DB.Where("user_id = ?", u.ID).Count("stars")
+	ms.NoError(err)
+	ms.Equal(0, stars)
+	follows, err := ms.DB.Where("followed_id = ?", u.ID).Count("follows")
+	ms.NoError(err)
+	ms.Equal(0, follows)
+	ms.NoError(ms.DB.Reload(godchild))
+	ms.Equal(sponsor.ID, godchild.SponsorID)
+
+	// her pending invitation goes, refunding nobody
+	exists, err = ms.DB.Where("id = ?", invitee.ID).Exists("users")
+	ms.NoError(err)
+	ms.False(exists)
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(0, sponsor.SponsorshipsCount)
+
+	// the ghost can't be deleted
+	ms.Error(models.DeleteAccount(ms.DB, ghost))
+}
+
+func (ms *ModelSuite) Test_DeleteAccount_Delete() {
+	u := &models.User{ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(u))
+	reader := &models.User{ProviderID: nulls.NewString("2")}
+	ms.NoError(ms.DB.Create(reader))
+
+	text := &models.Text{Title: "Up the ridge", Content: "Some steps", AuthorID: u.ID}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(ms.DB.Create(&models.Comment{TextID: text.ID, AuthorID: reader.ID, Content: "Nice"}))
+	_, err := models.StarText(ms.DB, reader.ID, text)
+	ms.NoError(err)
+
+	// her comments elsewhere: the one with a reply is only blanked out
+	other := &models.Text{Title: "Down the valley", Content: "More steps", AuthorID: reader.ID}
+	ms.NoError(ms.DB.Create(other))
+	answered := &models.Comment{TextID: other.ID, AuthorID: u.ID, Content: "Where is it?"}
+	ms.NoError(ms.DB.Create(answered))
+	ms.NoError(ms.DB.Create(&models.Comment{TextID: other.ID, AuthorID: reader.ID, ParentID: nulls.NewUUID(answered.ID), Content: "Up north"}))
+	ms.NoError(ms.DB.Create(&models.Comment{TextID: other.ID, AuthorID: u.ID, Content: "Thanks"}))
+
+	u.DeletionMode = models.DeletionDeleteContent
+	ms.NoError(models.DeleteAccount(ms.DB, u))
+
+	texts, err := ms.DB.Where("author_id = ?", u.ID).Count("texts")
+	ms.NoError(err)
+	ms.Equal(0, texts)
+	comments, err := ms.DB.Where("text_id = ?", text.ID).Count("comments")
+	ms.NoError(err)
+	ms.Equal(0, comments)
+	stars, err := ms.DB.Where("text_id = ?", text.ID).Count("stars")
+	ms.NoError(err)
+	ms.Equal(0, stars)
+
+	comments, err = ms.DB.Where("text_id = ?", other.ID).Count("comments")
+	ms.NoError(err)
+	ms.Equal(2, comments)
+	ms.NoError(ms.DB.Reload(answered))
+	ms.True(answered.Deleted)
+	ms.Equal("", answered.Content)
+	ms.NotEqual(u.ID, answered.AuthorID)
+}
+
+func (ms *ModelSuite) Test_DeleteAccount_SharedFlags() {
+	// a real user may go by the nickname the ghost used to have
+	ms.NoError(ms.DB.Create(&models.User{ProviderID: nulls.NewString("0"), Nickname: nulls.NewString("ghost")}))
+
+	author := &models.User{ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(author))
+	text := &models.Text{Title: "Up the ridge", Content: "Some steps", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+
+	// both flag the same text, then both go
+	for i, id := range []string{"2", "3"} {
+		u := &models.User{ProviderID: nulls.NewString(id), DeletionMode: models.DeletionAnonymizeContent}
+		ms.NoError(ms.DB.Create(u))
+		ms.NoError(ms.DB.Create(&models.Flag{UserID: u.ID, TextID: text.ID, Reason: "spam", Status: models.FlagPending}))
+		ms.NoError(models.DeleteAccount(ms.DB, u), "deleting user %d", i)
+	}
+
+	ghost, err := models.GhostUser(ms.DB)
+	ms.NoError(err)
+	flags, err := ms.DB.Where("text_id = ?", text.ID).Count("flags")
+	ms.NoError(err)
+	ms.Equal(1, flags)
+	exists, err := ms.DB.Where("text_id = ? AND user_id = ?", text.ID, ghost.ID).Exists("flags")
+	ms.NoError(err)
+	ms.True(exists)
+}
@@ -0,0 +1,103 @@
+package models
+
+import "strings"
+
+// DiffLine is one line of a line-level diff, Op is "+" for an added line,
+// "-" for a removed one and " " for a line both versions have in common
+type DiffLine struct {
+	Op   string
+	Text string
+}
+
+// Added is used by templates to color lines
+func (d DiffLine) Added() bool {
+	return d.Op == "+"
+}
+
+// Removed is used by templates to color lines
+func (d DiffLine) Removed() bool {
+	return d.Op == "-"
+}
+
+// MaxDiffCells caps the work LineDiff does: the number of changed lines
+// of one version times those of the other, once the lines both versions
+// start and end with are set aside
+const MaxDiffCells = 1 << 20
+
+// LineDiff compares two versions of a text line by line,
+// based on their longest common subsequence of lines.
+// It returns false, and no diff, when they differ too much to compare
+// within MaxDiffCells.
+func LineDiff(from, to string) ([]DiffLine, bool) {
+	a := strings.Split(strings.Replace(from, "\r\n", "\n", -1), "\n")
+	b := strings.Split(strings.Replace(to, "\r\n", "\n", -1), "\n")
+
+	// edits usually touch a few lines in the middle: the common start and end
+	// are kept as is, only what's left in between needs the full comparison
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > MaxDiffCells {
+		return nil, false
+	}
+
+	diff := []DiffLine{}
+	for _, line := range a[:prefix] {
+		diff = append(diff, DiffLine{Op: " ", Text: line})
+	}
+	diff = append(diff, lcsDiff(midA, midB)...)
+	for _, line := range a[len(a)-suffix:] {
+		diff = append(diff, DiffLine{Op: " ", Text: line})
+	}
+	return diff, true
+}
+
+// lcsDiff diffs the lines with a table of their longest common subsequences
+func lcsDiff(a, b []string) []DiffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []DiffLine{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: "+", Text: b[j]})
+	}
+	return diff
+}
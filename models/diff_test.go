@@ -0,0 +1,45 @@
+package models_test
+
+import (
+	"strings"
+
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_LineDiff() {
+	diff, ok := models.LineDiff("walk\ntalk\nsleep", "walk\ntalk more\nsleep\neat")
+	ms.True(ok)
+	ms.Equal([]models.DiffLine{
+		{Op: " ", Text: "walk"},
+		{Op: "-", Text: "talk"},
+		{Op: "+", Text: "talk more"},
+		{Op: " ", Text: "sleep"},
+		{Op: "+", Text: "eat"},
+	}, diff)
+
+	diff, ok = models.LineDiff("same\ntext", "same\ntext")
+	ms.True(ok)
+	for _, l := range diff {
+		ms.Equal(" ", l.Op)
+	}
+}
+
+func (ms *ModelSuite) Test_LineDiff_Large() {
+	long := func(prefix string, n int) string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = prefix + strings.Repeat("x", i%7)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	// a small edit in a long text only compares the lines around it
+	text := long("line", 5000)
+	diff, ok := models.LineDiff(text, strings.Replace(text, "line", "edited", 1))
+	ms.True(ok)
+	ms.Len(diff, 5001)
+
+	// two long texts with nothing in common are too much
+	_, ok = models.LineDiff(long("a", 2000), long("b", 2000))
+	ms.False(ok)
+}
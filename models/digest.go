@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// how often a user gets the email digest, see User.DigestFrequency
+const (
+	DigestNever  = "never"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestFrequencies are the choices offered on the profile
+var DigestFrequencies = []string{DigestNever, DigestDaily, DigestWeekly}
+
+// DigestPeriod returns how much time a digest of the given frequency covers,
+// 0 for "never" and anything unknown
+func DigestPeriod(frequency string) time.Duration {
+	switch frequency {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// Digest sums up what happened for a user since her last digest:
+// new texts from the users she follows or sponsored, and stars on her texts
+type Digest struct {
+	User    User
+	Since   time.Time
+	Texts   Texts
+	Starred []StarredText
+}
+
+// StarredText is one of the user's texts and the number of stars it got
+type StarredText struct {
+	Text  Text
+	Stars int
+}
+
+// Empty checks if there is anything worth sending
+func (d Digest) Empty() bool {
+	return len(d.Texts) == 0 && len(d.Starred) == 0
+}
+
+// DueForDigest returns the users with an email who get digests at the given frequency
+// and didn't get one for at least the digest period, as of now
+func DueForDigest(tx *pop.Connection, frequency string, now time.Time) (Users, error) {
+	users := Users{}
+	period := DigestPeriod(frequency)
+	if period == 0 {
+		return users, errors.Errorf("unknown digest frequency %q", frequency)
+	}
+
+	// a little slack so that a cron job running at the same time every day
+	// doesn't skip a day because the previous run was a few seconds late
+	cutoff := now.Add(-period + time.Hour)
+	err := tx.Where("digest_frequency = ? AND email IS NOT NULL AND email <> '' AND provider_id IS NOT NULL", frequency).
+		Where("(last_digest_at IS NULL OR last_digest_at <= ?)", cutoff).
+		All(&users)
+	return users, errors.WithStack(err)
+}
+
+// BuildDigest gathers what happened for the user since her last digest,
+// or over the last digest period if she never got one
+func BuildDigest(tx *pop.Connection, user User, now time.Time) (*Digest, error) {
+	since := now.Add(-DigestPeriod(user.DigestFrequency))
+	if user.LastDigestAt.Valid {
+		since = user.LastDigestAt.Time
+	}
+	d := &Digest{User: user, Since: since}
+
+	err := tx.Where("draft = ? AND hidden = ? AND published_at > ? AND published_at <= ?", false, false, since, now).
+		Where("(author_id IN (SELECT followed_id FROM follows WHERE follower_id = ?) OR author_id IN (SELECT id FROM users WHERE sponsor_id = ?))", user.ID, user.ID).
+		Order("published_at desc").
+		All(&d.Texts)
+	if err != nil {
+		return d, errors.WithStack(err)
+	}
+	if err := loadAuthors(tx, d.Texts); err != nil {
+		return d, err
+	}
+
+	stars := Stars{}
+	err = tx.Where("created_at > ? AND created_at <= ? AND text_id IN (SELECT id FROM texts WHERE author_id = ?)", since, now, user.ID).
+		All(&stars)
+	if err != nil {
+		return d, errors.WithStack(err)
+	}
+	if len(stars) == 0 {
+		return d, nil
+	}
+
+	counts := map[string]int{}
+	textIDs := []interface{}{}
+	for _, s := range stars {
+		if counts[s.TextID.String()] == 0 {
+			textIDs = append(textIDs, s.TextID)
+		}
+		counts[s.TextID.String()]++
+	}
+	texts := Texts{}
+	if err := tx.Where("id in (?)", textIDs...).Order("title asc").All(&texts); err != nil {
+		return d, errors.WithStack(err)
+	}
+	for _, t := range texts {
+		d.Starred = append(d.Starred, StarredText{Text: t, Stars: counts[t.ID.String()]})
+	}
+	return d, nil
+}
+
+// DigestSent records the user got her digest
+func DigestSent(tx *pop.Connection, user User, at time.Time) error {
+	err := tx.RawQuery("UPDATE users SET last_digest_at = ? WHERE id = ?", at, user.ID).Exec()
+	return errors.WithStack(err)
+}
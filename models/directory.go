@@ -0,0 +1,156 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// the orders the user directory can be sorted in, best first
+const (
+	SortByScore    = "score"
+	SortByTexts    = "texts"
+	SortByStars    = "stars"
+	SortBySignup   = "signup"
+	SortByActivity = "activity"
+)
+
+// DirectorySorts are the orders offered in the directory
+var DirectorySorts = []string{SortByScore, SortByTexts, SortByStars, SortBySignup, SortByActivity}
+
+// the periods of the leaderboard
+const (
+	LeaderboardWeek  = "week"
+	LeaderboardMonth = "month"
+	LeaderboardAll   = "all"
+)
+
+// LeaderboardPeriods are the periods offered on the leaderboard
+var LeaderboardPeriods = []string{LeaderboardWeek, LeaderboardMonth, LeaderboardAll}
+
+// last activity is whichever came last of logging in and posting
+const lastActiveSQL = "CASE WHEN u.last_posted_at > u.last_logged_at THEN u.last_posted_at ELSE u.last_logged_at END"
+
+var directoryOrders = map[string]string{
+	SortByScore:    "u.score DESC",
+	SortByTexts:    "texts_count DESC",
+	SortByStars:    "stars_count DESC",
+	SortBySignup:   "u.signedup_at DESC",
+	SortByActivity: "last_active_at DESC",
+}
+
+// DirectoryEntry is a user with the aggregates the directory and leaderboard show
+type DirectoryEntry struct {
+	User         User      `db:"-"`
+	UserID       uuid.UUID `db:"user_id"`
+	TextsCount   int       `db:"texts_count"`
+	StarsCount   int       `db:"stars_count"`
+	LastActiveAt time.Time `db:"last_active_at"`
+	Points       int       `db:"points"`
+}
+
+// DirectoryFilter narrows down the directory, empty fields don't filter
+type DirectoryFilter struct {
+	Sort      string
+	Provider  string
+	SponsorID uuid.UUID
+}
+
+// Directory lists the signed up users matching the filter with their published texts
+// and the stars those got, sorted as asked (by score if the sort is unknown).
+// The counts are computed by the database, one page at a time.
+func Directory(tx *pop.Connection, f DirectoryFilter, page, perPage int) ([]DirectoryEntry, *pop.Paginator, error) {
+	p := pop.NewPaginator(page, perPage)
+	entries := []DirectoryEntry{}
+
+	where := "u.provider_id IS NOT NULL"
+	args := []interface{}{}
+	if f.Provider != "" {
+		where += " AND u.provider = ?"
+		args = append(args, f.Provider)
+	}
+	if f.SponsorID != uuid.Nil {
+		where += " AND u.sponsor_id = ?"
+		args = append(args, f.SponsorID)
+	}
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := tx.RawQuery("SELECT count(*) AS count FROM users u WHERE "+where, args...).First(&count); err != nil {
+		return entries, p, errors.WithStack(err)
+	}
+	if count.Count == 0 {
+		setTotal(p, 0, 0)
+		return entries, p, nil
+	}
+
+	order, ok := directoryOrders[f.Sort]
+	if !ok {
+		order = directoryOrders[SortByScore]
+	}
+	sql := "SELECT u.id AS user_id, " +
+		"(SELECT count(*) FROM texts t WHERE t.author_id = u.id AND t.draft = ? AND t.hidden = ?) AS texts_count, " +
+		"(SELECT count(*) FROM stars s JOIN texts t ON t.id = s.text_id WHERE t.author_id = u.id AND t.draft = ? AND t.hidden = ?) AS stars_count, " +
+		lastActiveSQL + " AS last_active_at, " +
+		"u.score AS points " +
+		"FROM users u WHERE " + where +
+		" ORDER BY " + order + ", u.created_at ASC LIMIT ? OFFSET ?"
+	args = append([]interface{}{false, false, false, false}, args...)
+	args = append(args, p.PerPage, p.Offset)
+	if err := tx.RawQuery(sql, args...).All(&entries); err != nil {
+		return entries, p, errors.WithStack(err)
+	}
+	setTotal(p, count.Count, len(entries))
+
+	return entries, p, loadEntryUsers(tx, entries)
+}
+
+// Leaderboard returns the users who gained the most points over the period
+// according to their score history, "all" being their whole history
+func Leaderboard(tx *pop.Connection, period string, now time.Time, limit int) ([]DirectoryEntry, error) {
+	entries := []DirectoryEntry{}
+
+	where := "u.provider_id IS NOT NULL"
+	args := []interface{}{}
+	var since time.Time
+	switch period {
+	case LeaderboardWeek:
+		since = now.AddDate(0, 0, -7)
+	case LeaderboardMonth:
+		since = now.AddDate(0, -1, 0)
+	}
+	if !since.IsZero() {
+		// scores from before the history was kept weren't earned this week or month
+		where += " AND e.created_at >= ? AND e.reason <> ?"
+		args = append(args, since, ReasonOpeningBalance)
+	}
+
+	sql := "SELECT e.user_id AS user_id, SUM(e.points) AS points " +
+		"FROM score_events e JOIN users u ON u.id = e.user_id WHERE " + where +
+		" GROUP BY e.user_id HAVING SUM(e.points) > 0 ORDER BY points DESC, e.user_id LIMIT ?"
+	args = append(args, limit)
+	if err := tx.RawQuery(sql, args...).All(&entries); err != nil {
+		return entries, errors.WithStack(err)
+	}
+
+	return entries, loadEntryUsers(tx, entries)
+}
+
+// loadEntryUsers fills in the users of the entries with a single query
+func loadEntryUsers(tx *pop.Connection, entries []DirectoryEntry) error {
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.UserID
+	}
+	users, err := usersByID(tx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].User = users[entries[i].UserID]
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Directory() {
+	prolific := &models.User{ProviderID: nulls.NewString("1"), Provider: nulls.NewString("github"), Score: 1}
+	starred := &models.User{ProviderID: nulls.NewString("2"), Provider: nulls.NewString("twitter"), Score: 50, SponsorID: uuid.Must(uuid.NewV4())}
+	invitee := &models.User{Score: 100}
+	for _, u := range []*models.User{prolific, starred, invitee} {
+		ms.NoError(ms.DB.Create(u))
+	}
+	for i := 0; i < 3; i++ {
+		ms.NoError(ms.DB.Create(&models.Text{Title: "Walk", AuthorID: prolific.ID, PublishedAt: nulls.NewTime(time.Now())}))
+	}
+	ms.NoError(ms.DB.Create(&models.Text{Title: "Draft", AuthorID: starred.ID, Draft: true}))
+	text := &models.Text{Title: "Star", AuthorID: starred.ID, PublishedAt: nulls.NewTime(time.Now())}
+	ms.NoError(ms.DB.Create(text))
+	_, err := models.StarText(ms.DB, prolific.ID, text)
+	ms.NoError(err)
+	// stars on texts nobody can see don't count
+	hidden := &models.Text{Title: "Spam", AuthorID: starred.ID, Hidden: true, PublishedAt: nulls.NewTime(time.Now())}
+	ms.NoError(ms.DB.Create(hidden))
+	_, err = models.StarText(ms.DB, prolific.ID, hidden)
+	ms.NoError(err)
+
+	// pending invitations aren't members yet
+	entries, p, err := models.Directory(ms.DB, models.DirectoryFilter{Sort: models.SortByScore}, 1, 20)
+	ms.NoError(err)
+	ms.Equal(2, p.TotalEntriesSize)
+	ms.Len(entries, 2)
+	ms.Equal(starred.ID, entries[0].UserID)
+	ms.Equal(starred.ID, entries[0].User.ID)
+
+	entries, _, err = models.Directory(ms.DB, models.DirectoryFilter{Sort: models.SortByTexts}, 1, 20)
+	ms.NoError(err)
+	ms.Equal(prolific.ID, entries[0].UserID)
+	ms.Equal(3, entries[0].TextsCount)
+	ms.Equal(1, entries[1].TextsCount)
+
+	entries, _, err = models.Directory(ms.DB, models.DirectoryFilter{Sort: models.SortByStars}, 1, 20)
+	ms.NoError(err)
+	ms.Equal(starred.ID, entries[0].UserID)
+	ms.Equal(1, entries[0].StarsCount)
+
+	entries, _, err = models.Directory(ms.DB, models.DirectoryFilter{Provider: "github"}, 1, 20)
+	ms.NoError(err)
+	ms.Len(entries, 1)
+	ms.Equal(prolific.ID, entries[0].UserID)
+
+	entries, _, err = models.Directory(ms.DB, models.DirectoryFilter{SponsorID: starred.SponsorID}, 1, 20)
+	ms.NoError(err)
+	ms.Len(entries, 1)
+	ms.Equal(starred.ID, entries[0].UserID)
+}
+
+func (ms *ModelSuite) Test_Leaderboard() {
+	old := &models.User{ProviderID: nulls.NewString("1")}
+	recent := &models.User{ProviderID: nulls.NewString("2")}
+	ms.NoError(ms.DB.Create(old))
+	ms.NoError(ms.DB.Create(recent))
+
+	ms.NoError(ms.DB.Create(&models.ScoreEvent{UserID: old.ID, Points: 100, Reason: models.ReasonPosted, CreatedAt: time.Now().AddDate(0, -2, 0)}))
+	ms.NoError(ms.DB.Create(&models.ScoreEvent{UserID: recent.ID, Points: 10, Reason: models.ReasonPosted}))
+
+	week, err := models.Leaderboard(ms.DB, models.LeaderboardWeek, time.Now(), 10)
+	ms.NoError(err)
+	ms.Len(week, 1)
+	ms.Equal(recent.ID, week[0].UserID)
+	ms.Equal(10, week[0].Points)
+
+	all, err := models.Leaderboard(ms.DB, models.LeaderboardAll, time.Now(), 10)
+	ms.NoError(err)
+	ms.Len(all, 2)
+	ms.Equal(old.ID, all[0].UserID)
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// the statuses of an export
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// ExportTTL is how long a built export can be downloaded before it's pruned.
+// Defaults to 3 days, set EXPORT_TTL (e.g. "24h") to change it.
+var ExportTTL = 72 * time.Hour
+
+// ExportDir is where the export archives are written, set EXPORT_DIR to change it
+var ExportDir = envy.Get("EXPORT_DIR", "tmp/exports")
+
+// ExportSyncLimit is the number of texts up to which an export is built
+// right away, bigger ones are built in the background.
+// Set EXPORT_SYNC_LIMIT to change it.
+var ExportSyncLimit = 50
+
+func init() {
+	if d, err := time.ParseDuration(envy.Get("EXPORT_TTL", "72h")); err == nil && d > 0 {
+		ExportTTL = d
+	} else {
+		log.Printf("invalid EXPORT_TTL, using %s", ExportTTL)
+	}
+	if n, err := strconv.Atoi(envy.Get("EXPORT_SYNC_LIMIT", "50")); err == nil && n >= 0 {
+		ExportSyncLimit = n
+	} else {
+		log.Printf("invalid EXPORT_SYNC_LIMIT, using %d", ExportSyncLimit)
+	}
+}
+
+// Export is a zip archive of everything a user put on Kumano, for her to download
+type Export struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Status    string     `json:"status" db:"status"`
+	Size      int        `json:"size" db:"size"`
+	ExpiresAt nulls.Time `json:"expires_at" db:"expires_at"`
+}
+
+// String is not required by pop and may be deleted
+func (e Export) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// Exports is not required by pop and may be deleted
+type Exports []Export
+
+// String is not required by pop and may be deleted
+func (e Exports) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// Path is where the archive of the export is written
+func (e Export) Path() string {
+	return filepath.Join(ExportDir, e.ID.String()+".zip")
+}
+
+// Filename is the name the archive is downloaded as
+func (e Export) Filename() string {
+	return fmt.Sprintf("kumano-%s.zip", e.CreatedAt.Format("2006-01-02"))
+}
+
+// Downloadable checks if the archive is built and not expired yet
+func (e Export) Downloadable() bool {
+	return e.Status == ExportReady && e.ExpiresAt.Valid && time.Now().Before(e.ExpiresAt.Time)
+}
+
+// HasPendingExport checks if an export of the user is still being built.
+// Exports pending for over an hour are considered lost, e.g. to a restart.
+func HasPendingExport(tx *pop.Connection, userID uuid.UUID) (bool, error) {
+	exists, err := tx.Where("user_id = ? AND status = ? AND created_at > ?", userID, ExportPending, time.Now().Add(-time.Hour)).Exists("exports")
+	return exists, errors.WithStack(err)
+}
+
+// ExportIsLarge checks if the user has more than ExportSyncLimit texts,
+// in which case her export is better built in the background
+func ExportIsLarge(tx *pop.Connection, userID uuid.UUID) (bool, error) {
+	count, err := tx.Where("author_id = ?", userID).Count("texts")
+	return count > ExportSyncLimit, errors.WithStack(err)
+}
+
+// BuildExport writes the archive of the export and marks it ready
+// for ExportTTL, or failed if it couldn't be written
+func BuildExport(tx *pop.Connection, e *Export) error {
+	err := writeExportFile(tx, e)
+	if err != nil {
+		e.Status = ExportFailed
+		os.Remove(e.Path())
+	} else {
+		e.Status = ExportReady
+		e.ExpiresAt = nulls.NewTime(time.Now().Add(ExportTTL))
+	}
+	if uerr := tx.Update(e); uerr != nil {
+		return errors.WithStack(uerr)
+	}
+	return err
+}
+
+func writeExportFile(tx *pop.Connection, e *Export) error {
+	user := &User{}
+	if err := tx.Find(user, e.UserID); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(ExportDir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(e.Path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := WriteExport(tx, user, f); err != nil {
+		f.Close()
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	e.Size = int(info.Size())
+	return errors.WithStack(f.Close())
+}
+
+// exportProfile is the user's profile in profile.json
+type exportProfile struct {
+	ID                uuid.UUID    `json:"id"`
+	Nickname          nulls.String `json:"nickname"`
+	Name              nulls.String `json:"name"`
+	Email             nulls.String `json:"email"`
+	Bio               nulls.String `json:"bio"`
+	AvatarURL         nulls.String `json:"avatar_url"`
+	Provider          nulls.String `json:"provider"`
+	Score             int          `json:"score"`
+	SponsorID         uuid.UUID    `json:"sponsor_id"`
+	SponsorshipsCount int          `json:"sponsorships_count"`
+	SignedUpAt        time.Time    `json:"signedup_at"`
+	DigestFrequency   string       `json:"digest_frequency"`
+	Notifications     bool         `json:"email_notifications"`
+}
+
+// exportStar is a star in stars_given.json, with the text's author,
+// or in stars_received.json, with who starred the text
+type exportStar struct {
+	TextID    uuid.UUID    `json:"text_id" db:"text_id"`
+	Title     string       `json:"title" db:"title"`
+	UserID    uuid.UUID    `json:"user_id" db:"user_id"`
+	Nickname  nulls.String `json:"nickname" db:"nickname"`
+	StarredAt time.Time    `json:"starred_at" db:"starred_at"`
+}
+
+// exportInvitation is an invitation sent by the user in invitations.json
+type exportInvitation struct {
+	Email      nulls.String `json:"email"`
+	Nickname   nulls.String `json:"nickname,omitempty"`
+	InvitedAt  time.Time    `json:"invited_at"`
+	SignedUpAt *time.Time   `json:"signedup_at,omitempty"`
+}
+
+// WriteExport writes the zip archive of everything the user put on Kumano:
+//   - profile.json
+//   - texts/*.md, her texts including drafts, as Markdown with front matter
+//   - stars_given.json and stars_received.json
+//   - invitations.json, the people she invited
+func WriteExport(tx *pop.Connection, user *User, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	add := func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = f.Write(data)
+		return errors.WithStack(err)
+	}
+	addJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return add(name, data)
+	}
+
+	profile := exportProfile{
+		ID:                user.ID,
+		Nickname:          user.Nickname,
+		Name:              user.Name,
+		Email:             user.Email,
+		Bio:               user.Bio,
+		AvatarURL:         user.AvatarURL,
+		Provider:          user.Provider,
+		Score:             user.Score,
+		SponsorID:         user.SponsorID,
+		SponsorshipsCount: user.SponsorshipsCount,
+		SignedUpAt:        user.SignedUpAt,
+		DigestFrequency:   user.DigestFrequency,
+		Notifications:     user.EmailNotifications,
+	}
+	if err := addJSON("profile.json", profile); err != nil {
+		return err
+	}
+
+	texts := Texts{}
+	if err := tx.Eager("Tags").Where("author_id = ?", user.ID).Order("created_at").All(&texts); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, t := range texts {
+		md, err := t.Markdown()
+		if err != nil {
+			return err
+		}
+		if err := add(exportTextName(t), md); err != nil {
+			return err
+		}
+	}
+
+	given := []exportStar{}
+	err := tx.RawQuery("SELECT texts.id AS text_id, texts.title, users.id AS user_id, users.nickname, stars.created_at AS starred_at "+
+		"FROM stars JOIN texts ON texts.id = stars.text_id JOIN users ON users.id = texts.author_id "+
+		"WHERE stars.user_id = ? ORDER BY stars.created_at", user.ID).All(&given)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := addJSON("stars_given.json", given); err != nil {
+		return err
+	}
+
+	received := []exportStar{}
+	err = tx.RawQuery("SELECT texts.id AS text_id, texts.title, users.id AS user_id, users.nickname, stars.created_at AS starred_at "+
+		"FROM stars JOIN texts ON texts.id = stars.text_id JOIN users ON users.id = stars.user_id "+
+		"WHERE texts.author_id = ? ORDER BY stars.created_at", user.ID).All(&received)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := addJSON("stars_received.json", received); err != nil {
+		return err
+	}
+
+	invitees := Users{}
+	if err := tx.Where("sponsor_id = ?", user.ID).Order("invited_at").All(&invitees); err != nil {
+		return errors.WithStack(err)
+	}
+	invitations := make([]exportInvitation, len(invitees))
+	for i, u := range invitees {
+		invitations[i] = exportInvitation{Email: u.Email, Nickname: u.Nickname, InvitedAt: u.InvitedAt}
+		if !u.InvitationPending() && !u.SignedUpAt.IsZero() {
+			signedUpAt := u.SignedUpAt
+			invitations[i].SignedUpAt = &signedUpAt
+		}
+	}
+	if err := addJSON("invitations.json", invitations); err != nil {
+		return err
+	}
+
+	return errors.WithStack(zw.Close())
+}
+
+// exportTextName is the path of the text in the archive, by date then title,
+// with the start of its ID in case two texts share both
+func exportTextName(t Text) string {
+	at := t.CreatedAt
+	if t.PublishedAt.Valid {
+		at = t.PublishedAt.Time
+	}
+	slug := Slugify(t.Title)
+	if slug == "" {
+		slug = "untitled"
+	}
+	return fmt.Sprintf("texts/%s-%s-%s.md", at.Format("2006-01-02"), slug, t.ID.String()[:8])
+}
+
+// PruneExports deletes the archives past their expiry, and the failed exports
+// older than ExportTTL. It returns how many exports were deleted.
+func PruneExports(tx *pop.Connection, now time.Time) (int, error) {
+	exports := Exports{}
+	err := tx.Where("(status = ? AND expires_at < ?) OR (status = ? AND created_at < ?)",
+		ExportReady, now, ExportFailed, now.Add(-ExportTTL)).All(&exports)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for i := range exports {
+		if err := os.Remove(exports[i].Path()); err != nil && !os.IsNotExist(err) {
+			return i, errors.WithStack(err)
+		}
+		if err := tx.Destroy(&exports[i]); err != nil {
+			return i, errors.WithStack(err)
+		}
+	}
+	return len(exports), nil
+}
@@ -0,0 +1,96 @@
+package models_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_WriteExport() {
+	u := &models.User{Nickname: nulls.NewString("walker"), ProviderID: nulls.NewString("1")}
+	fan := &models.User{Nickname: nulls.NewString("fan"), ProviderID: nulls.NewString("2")}
+	ms.NoError(ms.DB.Create(u))
+	ms.NoError(ms.DB.Create(fan))
+	ms.NoError(ms.DB.Create(&models.User{Email: nulls.NewString("friend@example.com"), InvitationToken: "token", InvitedAt: time.Now(), SponsorID: u.ID}))
+
+	text := &models.Text{Title: "Up the ridge", Content: "Some *steps*", AuthorID: u.ID, PublishedAt: nulls.NewTime(time.Now())}
+	ms.NoError(ms.DB.Create(text))
+	text.Tags = models.ParseTags("hiking")
+	ms.NoError(models.SaveTextTags(ms.DB, text))
+	ms.NoError(ms.DB.Create(&models.Text{Title: "Secret path", AuthorID: u.ID, Draft: true}))
+	_, err := models.StarText(ms.DB, fan.ID, text)
+	ms.NoError(err)
+
+	var b bytes.Buffer
+	ms.NoError(models.WriteExport(ms.DB, u, &b))
+
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	ms.NoError(err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		ms.NoError(err)
+		data, err := ioutil.ReadAll(rc)
+		ms.NoError(err)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	ms.Contains(files["profile.json"], `"nickname": "walker"`)
+	ms.Contains(files["stars_received.json"], `"nickname": "fan"`)
+	ms.Equal("[]", files["stars_given.json"])
+	ms.Contains(files["invitations.json"], "friend@example.com")
+
+	texts := 0
+	for name, content := range files {
+		if !strings.HasPrefix(name, "texts/") {
+			continue
+		}
+		texts++
+		if strings.Contains(name, "up-the-ridge") {
+			ms.True(strings.HasPrefix(content, "---\n"))
+			ms.Contains(content, "title: Up the ridge")
+			ms.Contains(content, "- hiking")
+			ms.Contains(content, "Some *steps*")
+		} else {
+			ms.Contains(content, "draft: true")
+		}
+	}
+	ms.Equal(2, texts)
+}
+
+func (ms *ModelSuite) Test_BuildExport_Prune() {
+	dir, err := ioutil.TempDir("", "exports")
+	ms.NoError(err)
+	defer os.RemoveAll(dir)
+	defer func(d string) { models.ExportDir = d }(models.ExportDir)
+	models.ExportDir = dir
+
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+	export := &models.Export{UserID: u.ID, Status: models.ExportPending}
+	ms.NoError(ms.DB.Create(export))
+
+	ms.NoError(models.BuildExport(ms.DB, export))
+	ms.Equal(models.ExportReady, export.Status)
+	ms.True(export.Downloadable())
+	ms.True(export.Size > 0)
+	_, err = os.Stat(export.Path())
+	ms.NoError(err)
+
+	count, err := models.PruneExports(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	count, err = models.PruneExports(ms.DB, time.Now().Add(models.ExportTTL+time.Hour))
+	ms.NoError(err)
+	ms.Equal(1, count)
+	_, err = os.Stat(export.Path())
+	ms.True(os.IsNotExist(err))
+}
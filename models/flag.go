@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// a flag goes from pending to either dismissed or upheld
+// once an admin has looked at it in the moderation queue
+const (
+	FlagPending   = "pending"
+	FlagDismissed = "dismissed"
+	FlagUpheld    = "upheld"
+)
+
+// FlagReasons are the reasons a user can choose from when flagging a text
+var FlagReasons = []string{"spam", "abuse", "off-topic", "other"}
+
+// TrustedFlagsToHide is how many users with PrivilegeSkipModeration have to flag
+// a text for it to be taken down before an admin reviews the flags, so that
+// no single user can hide a text on her own.
+// Defaults to 3, set TRUSTED_FLAGS_TO_HIDE to change it.
+var TrustedFlagsToHide = 3
+
+func init() {
+	if n, err := strconv.Atoi(envy.Get("TRUSTED_FLAGS_TO_HIDE", "3")); err == nil && n > 0 {
+		TrustedFlagsToHide = n
+	} else {
+		log.Printf("invalid TRUSTED_FLAGS_TO_HIDE, using %d", TrustedFlagsToHide)
+	}
+}
+
+// Flag is a report from a user about a text that should be looked at by an admin
+type Flag struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	User       User       `belongs_to:"user" db:"-"`
+	TextID     uuid.UUID  `json:"text_id" db:"text_id"`
+	Text       Text       `belongs_to:"text" db:"-"`
+	Reason     string     `json:"reason" db:"reason"`
+	Status     string     `json:"status" db:"status"`
+	ResolvedBy nulls.UUID `json:"resolved_by" db:"resolved_by"`
+	ResolvedAt nulls.Time `json:"resolved_at" db:"resolved_at"`
+}
+
+// String is not required by pop and may be deleted
+func (f Flag) String() string {
+	jf, _ := json.Marshal(f)
+	return string(jf)
+}
+
+// Flags is not required by pop and may be deleted
+type Flags []Flag
+
+// String is not required by pop and may be deleted
+func (f Flags) String() string {
+	jf, _ := json.Marshal(f)
+	return string(jf)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (f *Flag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: f.UserID, Name: "UserID"},
+		&validators.UUIDIsPresent{Field: f.TextID, Name: "TextID"},
+		&validators.StringInclusion{Field: f.Reason, Name: "Reason", List: FlagReasons, Message: "Please pick one of the reasons offered."},
+		&validators.StringInclusion{Field: f.Status, Name: "Status", List: []string{FlagPending, FlagDismissed, FlagUpheld}},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// A user gets to flag a given text only once.
+func (f *Flag) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	exists, err := tx.Where("user_id = ? AND text_id = ?", f.UserID, f.TextID).Exists("flags")
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if exists {
+		verrs.Add("flag", "You already flagged this text, thanks. An admin will look at it.")
+	}
+	return verrs, nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (f *Flag) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// Dismiss closes the flag without consequences for the author.
+// A text taken down while waiting for review is put back up
+// once no other flag on it is pending or upheld.
+func (f *Flag) Dismiss(tx *pop.Connection, admin *User) error {
+	f.resolve(FlagDismissed, admin)
+	if err := tx.Update(f); err != nil {
+		return errors.WithStack(err)
+	}
+
+	standing, err := tx.Where("text_id = ? AND status IN (?, ?)", f.TextID, FlagPending, FlagUpheld).Exists("flags")
+	if err != nil || standing {
+		return errors.WithStack(err)
+	}
+	err = tx.RawQuery("UPDATE texts SET hidden = ? WHERE id = ?", false, f.TextID).Exec()
+	return errors.WithStack(err)
+}
+
+// HideUntilReviewed takes the flagged text down right away once TrustedFlagsToHide
+// users with PrivilegeSkipModeration have pending flags on it, and tells if it did.
+// The author is only penalized if an admin upholds the flags,
+// dismissing them puts the text back up.
+func (f *Flag) HideUntilReviewed(tx *pop.Connection) (bool, error) {
+	trusted, err := tx.Where("text_id = ? AND status = ? AND user_id IN (SELECT id FROM users WHERE is_admin = ? OR score >= ?)",
+		f.TextID, FlagPending, true, PrivilegeThreshold(PrivilegeSkipModeration)).
+		Count("flags")
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if trusted < TrustedFlagsToHide {
+		return false, nil
+	}
+	err = tx.RawQuery("UPDATE texts SET hidden = ? WHERE id = ?", true, f.TextID).Exec()
+	return err == nil, errors.WithStack(err)
+}
+
+// Uphold hides the flagged text, closes every pending flag on it
+// and takes the PointsTextFlagged penalty from the author's score.
+// The penalty is applied once per text, however many users flagged it.
+func (f *Flag) Uphold(tx *pop.Connection, admin *User) error {
+	text := &Text{}
+	if err := tx.Find(text, f.TextID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the text may already be hidden, waiting for review,
+	// so it's the upheld flags that tell if the author already paid
+	penalized, err := tx.Where("text_id = ? AND status = ?", f.TextID, FlagUpheld).Exists("flags")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	pending := Flags{}
+	if err := tx.Where("text_id = ? AND status = ?", f.TextID, FlagPending).All(&pending); err != nil {
+		return errors.WithStack(err)
+	}
+	for i := range pending {
+		pending[i].resolve(FlagUpheld, admin)
+		if err := tx.Update(&pending[i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	f.resolve(FlagUpheld, admin)
+
+	if penalized {
+		// already taken down by a previous flag, author already paid for it
+		return nil
+	}
+	if !text.Hidden {
+		text.Hidden = true
+		if err := tx.Update(text); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return addScore(tx, text.AuthorID, PointsTextFlagged, ReasonFlagged, SourceText, text.ID)
+}
+
+func (f *Flag) resolve(status string, admin *User) {
+	f.Status = status
+	f.ResolvedBy = nulls.NewUUID(admin.ID)
+	f.ResolvedAt = nulls.NewTime(time.Now())
+}
@@ -0,0 +1,98 @@
+package models_test
+
+import (
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Flag_Validate() {
+	flag := &models.Flag{
+		UserID: uuid.Must(uuid.NewV4()),
+		TextID: uuid.Must(uuid.NewV4()),
+		Reason: "because",
+		Status: models.FlagPending,
+	}
+	verrs, err := flag.Validate(ms.DB)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	ms.NotEmpty(verrs.Get("reason"))
+
+	flag.Reason = "spam"
+	verrs, err = flag.Validate(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+}
+
+func (ms *ModelSuite) Test_Flag_OnePerUserPerText() {
+	flag := &models.Flag{
+		UserID: uuid.Must(uuid.NewV4()),
+		TextID: uuid.Must(uuid.NewV4()),
+		Reason: "spam",
+		Status: models.FlagPending,
+	}
+	verrs, err := ms.DB.ValidateAndCreate(flag)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	again := &models.Flag{UserID: flag.UserID, TextID: flag.TextID, Reason: "abuse", Status: models.FlagPending}
+	verrs, err = ms.DB.ValidateAndCreate(again)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+}
+
+func (ms *ModelSuite) Test_Flag_HideUntilReviewed() {
+	author := &models.User{}
+	admin := &models.User{IsAdmin: true}
+	ms.NoError(ms.DB.Create(author))
+	ms.NoError(ms.DB.Create(admin))
+	text := &models.Text{Title: "Kumano Kodo", Content: "A walk", AuthorID: author.ID}
+	other := &models.Text{Title: "Nakahechi", Content: "Another walk", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(ms.DB.Create(other))
+
+	trusted := models.PrivilegeThreshold(models.PrivilegeSkipModeration)
+	flag := func(text *models.Text, score int) (*models.Flag, bool) {
+		u := &models.User{Score: score}
+		ms.NoError(ms.DB.Create(u))
+		f := &models.Flag{UserID: u.ID, TextID: text.ID, Reason: "spam", Status: models.FlagPending}
+		ms.NoError(ms.DB.Create(f))
+		hidden, err := f.HideUntilReviewed(ms.DB)
+		ms.NoError(err)
+		return f, hidden
+	}
+	hideWithTrustedFlags := func(text *models.Text) *models.Flag {
+		// untrusted flags don't count, nor does a single trusted one
+		_, hidden := flag(text, 0)
+		ms.False(hidden)
+		for i := 1; i < models.TrustedFlagsToHide; i++ {
+			_, hidden := flag(text, trusted)
+			ms.False(hidden)
+		}
+		ms.NoError(ms.DB.Reload(text))
+		ms.False(text.Hidden)
+
+		f, hidden := flag(text, trusted)
+		ms.True(hidden)
+		ms.NoError(ms.DB.Reload(text))
+		ms.True(text.Hidden)
+		return f
+	}
+
+	// dismissing every flag puts it back up
+	hideWithTrustedFlags(other)
+	pending := models.Flags{}
+	ms.NoError(ms.DB.Where("text_id = ?", other.ID).All(&pending))
+	for i := range pending {
+		ms.NoError(pending[i].Dismiss(ms.DB, admin))
+	}
+	ms.NoError(ms.DB.Reload(other))
+	ms.False(other.Hidden)
+
+	// upholding still penalizes the author, even though the text was already hidden
+	f := hideWithTrustedFlags(text)
+	ms.NoError(f.Uphold(ms.DB, admin))
+	ms.NoError(ms.DB.Reload(text))
+	ms.True(text.Hidden)
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(models.PointsTextFlagged, author.Score)
+}
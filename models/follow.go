@@ -0,0 +1,164 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// Follow is a user subscribing to another user's texts,
+// their published texts show up on the follower's home timeline
+type Follow struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	FollowerID uuid.UUID `json:"follower_id" db:"follower_id"`
+	FollowedID uuid.UUID `json:"followed_id" db:"followed_id"`
+}
+
+// String is not required by pop and may be deleted
+func (f Follow) String() string {
+	jf, _ := json.Marshal(f)
+	return string(jf)
+}
+
+// Follows is not required by pop and may be deleted
+type Follows []Follow
+
+// String is not required by pop and may be deleted
+func (f Follows) String() string {
+	jf, _ := json.Marshal(f)
+	return string(jf)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (f *Follow) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if f.FollowerID == f.FollowedID {
+		verrs.Add("follow", "You can't follow yourself.")
+	}
+	return verrs, nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// A user follows another one only once, see also the unique index on follows.
+func (f *Follow) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	following, err := IsFollowing(tx, f.FollowerID, f.FollowedID)
+	if err != nil {
+		return verrs, err
+	}
+	if following {
+		verrs.Add("follow", "You already follow this user.")
+	}
+	return verrs, nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (f *Follow) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// IsFollowing checks if follower already follows followed
+func IsFollowing(tx *pop.Connection, followerID, followedID uuid.UUID) (bool, error) {
+	exists, err := tx.Where("follower_id = ? AND followed_id = ?", followerID, followedID).Exists("follows")
+	return exists, errors.WithStack(err)
+}
+
+// CountFollowers returns the number of users following the user
+func CountFollowers(tx *pop.Connection, userID uuid.UUID) (int, error) {
+	count, err := tx.Where("followed_id = ?", userID).Count("follows")
+	return count, errors.WithStack(err)
+}
+
+// CountFollowing returns the number of users the user follows
+func CountFollowing(tx *pop.Connection, userID uuid.UUID) (int, error) {
+	count, err := tx.Where("follower_id = ?", userID).Count("follows")
+	return count, errors.WithStack(err)
+}
+
+// FollowUser makes follower follow followed. Following an already followed user
+// is a no-op, the returned bool tells if a follow was actually added.
+func FollowUser(tx *pop.Connection, followerID, followedID uuid.UUID) (bool, error) {
+	follow := &Follow{
+		FollowerID: followerID,
+		FollowedID: followedID,
+	}
+	verrs, err := tx.ValidateAndCreate(follow)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return !verrs.HasAny(), nil
+}
+
+// UnfollowUser makes follower stop following followed. Unfollowing a user that wasn't
+// followed is a no-op, the returned bool tells if a follow was actually removed.
+func UnfollowUser(tx *pop.Connection, followerID, followedID uuid.UUID) (bool, error) {
+	follow := &Follow{}
+	err := tx.Where("follower_id = ? AND followed_id = ?", followerID, followedID).First(follow)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+
+	if err := tx.Destroy(follow); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// Followers returns a paginated query for the users following the user
+func Followers(tx *pop.Connection, userID uuid.UUID, params pop.PaginationParams) *pop.Query {
+	return tx.PaginateFromParams(params).
+		Where("id IN (SELECT follower_id FROM follows WHERE followed_id = ?)", userID).
+		Order("name asc")
+}
+
+// Following returns a paginated query for the users the user follows
+func Following(tx *pop.Connection, userID uuid.UUID, params pop.PaginationParams) *pop.Query {
+	return tx.PaginateFromParams(params).
+		Where("id IN (SELECT followed_id FROM follows WHERE follower_id = ?)", userID).
+		Order("name asc")
+}
+
+// Timeline retrieves a page of the texts published by the users the user follows,
+// newest first. Authors are loaded with a single query for the whole page
+// rather than one per text like Eager does.
+func Timeline(tx *pop.Connection, userID uuid.UUID, params pop.PaginationParams) (Texts, *pop.Paginator, error) {
+	texts := Texts{}
+	q := tx.PaginateFromParams(params).
+		Where("draft = ? AND hidden = ?", false, false).
+		Where("author_id IN (SELECT followed_id FROM follows WHERE follower_id = ?)", userID).
+		Order("published_at desc")
+	if err := q.All(&texts); err != nil {
+		return texts, q.Paginator, errors.WithStack(err)
+	}
+	if err := loadAuthors(tx, texts); err != nil {
+		return texts, q.Paginator, err
+	}
+	return texts, q.Paginator, nil
+}
+
+// loadAuthors fills in the Author of each text with one query
+func loadAuthors(tx *pop.Connection, texts Texts) error {
+	ids := make([]uuid.UUID, len(texts))
+	for i, t := range texts {
+		ids[i] = t.AuthorID
+	}
+	authors, err := usersByID(tx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range texts {
+		texts[i].Author = authors[texts[i].AuthorID]
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+type params map[string]string
+
+func (p params) Get(key string) string {
+	return p[key]
+}
+
+func (ms *ModelSuite) Test_Follow_FollowUser() {
+	alice := &models.User{}
+	bob := &models.User{}
+	ms.NoError(ms.DB.Create(alice))
+	ms.NoError(ms.DB.Create(bob))
+
+	followed, err := models.FollowUser(ms.DB, alice.ID, bob.ID)
+	ms.NoError(err)
+	ms.True(followed)
+
+	// following twice is a no-op
+	followed, err = models.FollowUser(ms.DB, alice.ID, bob.ID)
+	ms.NoError(err)
+	ms.False(followed)
+
+	// no following oneself
+	followed, err = models.FollowUser(ms.DB, alice.ID, alice.ID)
+	ms.NoError(err)
+	ms.False(followed)
+
+	count, err := models.CountFollowers(ms.DB, bob.ID)
+	ms.NoError(err)
+	ms.Equal(1, count)
+	count, err = models.CountFollowing(ms.DB, alice.ID)
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	following := models.Users{}
+	ms.NoError(models.Following(ms.DB, alice.ID, params{}).All(&following))
+	ms.Len(following, 1)
+	ms.Equal(bob.ID, following[0].ID)
+
+	unfollowed, err := models.UnfollowUser(ms.DB, alice.ID, bob.ID)
+	ms.NoError(err)
+	ms.True(unfollowed)
+	unfollowed, err = models.UnfollowUser(ms.DB, alice.ID, bob.ID)
+	ms.NoError(err)
+	ms.False(unfollowed)
+}
+
+func (ms *ModelSuite) Test_Follow_Timeline() {
+	reader := &models.User{}
+	followed := &models.User{Name: nulls.NewString("Followed")}
+	stranger := &models.User{}
+	for _, u := range []*models.User{reader, followed, stranger} {
+		ms.NoError(ms.DB.Create(u))
+	}
+	_, err := models.FollowUser(ms.DB, reader.ID, followed.ID)
+	ms.NoError(err)
+
+	older := &models.Text{Title: "Older", AuthorID: followed.ID, PublishedAt: nulls.NewTime(time.Now().Add(-2 * time.Hour))}
+	newer := &models.Text{Title: "Newer", AuthorID: followed.ID, PublishedAt: nulls.NewTime(time.Now().Add(-time.Hour))}
+	draft := &models.Text{Title: "Draft", AuthorID: followed.ID, Draft: true}
+	other := &models.Text{Title: "Other", AuthorID: stranger.ID, PublishedAt: nulls.NewTime(time.Now())}
+	for _, t := range []*models.Text{older, newer, draft, other} {
+		ms.NoError(ms.DB.Create(t))
+	}
+
+	texts, p, err := models.Timeline(ms.DB, reader.ID, params{})
+	ms.NoError(err)
+	ms.Equal(2, p.TotalEntriesSize)
+	ms.Len(texts, 2)
+	ms.Equal(newer.ID, texts[0].ID)
+	ms.Equal(older.ID, texts[1].ID)
+	ms.Equal("Followed", texts[0].Author.Name.String)
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// limits on what can be imported at once
+const (
+	MaxImportSize  = 10 << 20 // bytes, of the uploaded file
+	MaxImportFiles = 200
+	// zips can decompress to far more than their size, these bound what is read from them
+	MaxImportFileSize     = 1 << 20  // bytes, of a Markdown file once decompressed
+	MaxImportUncompressed = 20 << 20 // bytes, of all the Markdown files once decompressed
+)
+
+// ImportItem is one Markdown file of an import, its Text is nil when
+// the file couldn't be read and Error says why
+type ImportItem struct {
+	Name  string
+	Text  *Text
+	Date  time.Time
+	Error string
+}
+
+// ReadImport reads an uploaded Markdown file, or a zip of them, without saving anything.
+// Files of a zip that aren't Markdown are left out, it is an error if there is none.
+func ReadImport(name string, data []byte) ([]ImportItem, error) {
+	if !strings.EqualFold(path.Ext(name), ".zip") {
+		if !isMarkdownFile(name) {
+			return nil, errors.New("upload a Markdown (.md) file or a zip of them")
+		}
+		return []ImportItem{parseImportItem(name, data)}, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "unreadable zip")
+	}
+	items := []ImportItem{}
+	budget := int64(MaxImportUncompressed)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !isMarkdownFile(f.Name) {
+			continue
+		}
+		if len(items) == MaxImportFiles {
+			return nil, errors.Errorf("too many files, import at most %d at once", MaxImportFiles)
+		}
+		tooLarge := ImportItem{Name: f.Name, Error: fmt.Sprintf("file too large, at most %d KB", MaxImportFileSize>>10)}
+		// the size in the zip headers is only a hint, what is read is limited below
+		if f.UncompressedSize64 > MaxImportFileSize {
+			items = append(items, tooLarge)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			items = append(items, ImportItem{Name: f.Name, Error: err.Error()})
+			continue
+		}
+		content, err := ioutil.ReadAll(io.LimitReader(rc, MaxImportFileSize+1))
+		rc.Close()
+		budget -= int64(len(content))
+		if budget < 0 {
+			return nil, errors.Errorf("zip too large once decompressed, import at most %d MB at once", MaxImportUncompressed>>20)
+		}
+		if err != nil {
+			items = append(items, ImportItem{Name: f.Name, Error: err.Error()})
+			continue
+		}
+		if len(content) > MaxImportFileSize {
+			items = append(items, tooLarge)
+			continue
+		}
+		items = append(items, parseImportItem(f.Name, content))
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no Markdown (.md) file in the zip")
+	}
+	return items, nil
+}
+
+// isMarkdownFile checks the file is Markdown by its extension,
+// leaving out hidden files like the ones macOS adds to zips
+func isMarkdownFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(base))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func parseImportItem(name string, data []byte) ImportItem {
+	item := ImportItem{Name: name}
+	text, date, err := ParseMarkdown(name, data)
+	if err != nil {
+		item.Error = errors.Cause(err).Error()
+		return item
+	}
+	item.Text = text
+	item.Date = date
+	return item
+}
+
+// SaveImport creates the readable items' texts as drafts of the author,
+// with their tags. Texts keep the date of their front matter as creation date.
+// The items that fail validation get their Error set. It returns how many texts were created.
+func SaveImport(tx *pop.Connection, authorID uuid.UUID, items []ImportItem) (int, error) {
+	created := 0
+	for i := range items {
+		item := &items[i]
+		if item.Text == nil {
+			continue
+		}
+		text := item.Text
+		text.AuthorID = authorID
+		text.Draft = true
+
+		verrs, err := tx.ValidateAndCreate(text)
+		if err != nil {
+			return created, errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			item.Error = verrs.Error()
+			continue
+		}
+		if !item.Date.IsZero() {
+			err := tx.RawQuery("UPDATE texts SET created_at = ? WHERE id = ?", item.Date, text.ID).Exec()
+			if err != nil {
+				return created, errors.WithStack(err)
+			}
+			text.CreatedAt = item.Date
+		}
+		if err := RecordRevision(tx, text, authorID); err != nil {
+			return created, err
+		}
+		if err := SaveTextTags(tx, text); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
@@ -0,0 +1,127 @@
+package models_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_ParseMarkdown() {
+	text, date, err := models.ParseMarkdown("ridge.md", []byte("---\ntitle: Up the ridge\ndate: 2018-06-02\ntags: hiking, mountains\ndraft: false\n---\n\nSome *steps*\n"))
+	ms.NoError(err)
+	ms.Equal("Up the ridge", text.Title)
+	ms.Equal("Some *steps*\n", text.Content)
+	ms.Equal("hiking, mountains", text.Tags.Names())
+	ms.True(text.Draft)
+	ms.Equal(time.Date(2018, 6, 2, 0, 0, 0, 0, time.UTC), date)
+
+	// no front matter: the title comes from the heading, else the file name
+	text, date, err = models.ParseMarkdown("notes/ridge.md", []byte("# The ridge\r\n\r\nSteps\r\n"))
+	ms.NoError(err)
+	ms.Equal("The ridge", text.Title)
+	ms.Equal("Steps\n", text.Content)
+	ms.True(date.IsZero())
+	text, _, err = models.ParseMarkdown("notes/ridge.md", []byte("Steps"))
+	ms.NoError(err)
+	ms.Equal("ridge", text.Title)
+
+	_, _, err = models.ParseMarkdown("a.md", []byte("---\ntitle: Open\nSteps"))
+	ms.Error(err)
+	_, _, err = models.ParseMarkdown("a.md", []byte("---\ndate: someday\n---\nSteps"))
+	ms.Error(err)
+	_, _, err = models.ParseMarkdown("a.md", []byte("---\ntitle: Empty\n---\n"))
+	ms.Error(err)
+}
+
+func (ms *ModelSuite) Test_Markdown_RoundTrip() {
+	text := models.Text{Title: "Up the ridge", Content: "Some *steps*", CreatedAt: time.Date(2018, 6, 2, 10, 0, 0, 0, time.UTC), Tags: models.ParseTags("hiking")}
+	md, err := text.Markdown()
+	ms.NoError(err)
+
+	parsed, date, err := models.ParseMarkdown("ridge.md", md)
+	ms.NoError(err)
+	ms.Equal(text.Title, parsed.Title)
+	ms.Equal("Some *steps*\n", parsed.Content)
+	ms.Equal("hiking", parsed.Tags.Names())
+	ms.True(text.CreatedAt.Equal(date))
+}
+
+func (ms *ModelSuite) Test_ReadImport_SaveImport() {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range map[string]string{
+		"posts/ridge.md":        "---\ntitle: Up the ridge\ndate: 2018-06-02\ntags: [hiking]\n---\nSteps\n",
+		"posts/empty.md":        "",
+		"posts/image.png":       "not markdown",
+		"__MACOSX/posts/._a.md": "junk",
+		"posts/valley.markdown": "# Down the valley\n\nMore steps\n",
+	} {
+		f, err := zw.Create(name)
+		ms.NoError(err)
+		f.Write([]byte(content))
+	}
+	ms.NoError(zw.Close())
+
+	items, err := models.ReadImport("blog.zip", b.Bytes())
+	ms.NoError(err)
+	ms.Len(items, 3)
+
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+	created, err := models.SaveImport(ms.DB, u.ID, items)
+	ms.NoError(err)
+	ms.Equal(2, created)
+
+	texts := models.Texts{}
+	ms.NoError(ms.DB.Where("author_id = ?", u.ID).Order("created_at").All(&texts))
+	ms.Len(texts, 2)
+	ms.Equal("Up the ridge", texts[0].Title)
+	ms.Equal(2018, texts[0].CreatedAt.Year())
+	for _, t := range texts {
+		ms.True(t.Draft)
+		ms.False(t.PublishedAt.Valid)
+	}
+
+	// drafts don't count against the posting quota
+	ok, _, err := u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.True(ok)
+
+	_, err = models.ReadImport("notes.txt", []byte("Steps"))
+	ms.Error(err)
+}
+
+func (ms *ModelSuite) Test_ReadImport_ZipBomb() {
+	zipOf := func(sizes ...int) []byte {
+		var b bytes.Buffer
+		zw := zip.NewWriter(&b)
+		for i, size := range sizes {
+			f, err := zw.Create(fmt.Sprintf("posts/%d.md", i))
+			ms.NoError(err)
+			_, err = f.Write(bytes.Repeat([]byte("a"), size))
+			ms.NoError(err)
+		}
+		ms.NoError(zw.Close())
+		ms.True(b.Len() < models.MaxImportSize)
+		return b.Bytes()
+	}
+
+	// a file too large is left out, the others are still read
+	items, err := models.ReadImport("blog.zip", zipOf(models.MaxImportFileSize+1, 10))
+	ms.NoError(err)
+	ms.Len(items, 2)
+	ms.Nil(items[0].Text)
+	ms.Contains(items[0].Error, "too large")
+	ms.NotNil(items[1].Text)
+
+	// files small enough on their own can't add up past the budget
+	sizes := make([]int, models.MaxImportUncompressed/models.MaxImportFileSize+1)
+	for i := range sizes {
+		sizes[i] = models.MaxImportFileSize
+	}
+	_, err = models.ReadImport("blog.zip", zipOf(sizes...))
+	ms.Error(err)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// An invitation is a User row with an InvitationToken and no provider yet.
+// Sending one consumes one of the sponsor's SponsorshipsCount, revoking it
+// or letting it expire gives it back. Admins and users with PrivilegeInvite
+// invite without counting.
+
+// InvitationTTL is how long an invitation can be redeemed.
+// Defaults to 7 days, set INVITATION_TTL (e.g. "72h") to change it.
+var InvitationTTL = 7 * 24 * time.Hour
+
+func init() {
+	if d, err := time.ParseDuration(envy.Get("INVITATION_TTL", "168h")); err == nil && d > 0 {
+		InvitationTTL = d
+	} else {
+		log.Printf("invalid INVITATION_TTL, using %s", InvitationTTL)
+	}
+}
+
+// CanInvite checks if the user has sponsorships left or PrivilegeInvite, admins always can
+func (u *User) CanInvite() bool {
+	return u.SponsorshipsCount > 0 || u.Can(PrivilegeInvite)
+}
+
+// InvitationPending checks if the user is an invitation that wasn't redeemed yet
+func (u User) InvitationPending() bool {
+	return u.InvitationToken != "" && !u.ProviderID.Valid
+}
+
+// InvitationExpiresAt is when the invitation can't be redeemed anymore
+func (u User) InvitationExpiresAt() time.Time {
+	return u.InvitedAt.Add(InvitationTTL)
+}
+
+// InvitationExpired checks if the invitation is past its TTL
+func (u User) InvitationExpired() bool {
+	return time.Now().After(u.InvitationExpiresAt())
+}
+
+// InviteUser creates the invitation of invitee, who only has an email yet,
+// by sponsor and consumes one of the sponsor's sponsorships.
+// The invitation email is up to the caller.
+func InviteUser(tx *pop.Connection, sponsor *User, invitee *User) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !sponsor.CanInvite() {
+		verrs.Add("sponsorships_count", "You have no invitations left.")
+		return verrs, nil
+	}
+
+	token, err := uuid.NewV4()
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	invitee.InvitationToken = token.String()
+	invitee.InvitedAt = time.Now()
+	invitee.SponsorID = sponsor.ID
+	invitee.SponsorshipUsed = !sponsor.Can(PrivilegeInvite)
+
+	// FIXME: check unique email
+	verrs, err = tx.ValidateAndCreate(invitee, "provider", "provider_id")
+	if err != nil || verrs.HasAny() {
+		return verrs, errors.WithStack(err)
+	}
+
+	if invitee.SponsorshipUsed {
+		// sponsor may be stale, e.g. when inviting twice at the same time:
+		// only take the sponsorship if the db still has one left
+		consumed, err := consumeSponsorship(tx, sponsor.ID)
+		if err != nil {
+			return verrs, err
+		}
+		if !consumed {
+			if err := tx.Destroy(invitee); err != nil {
+				return verrs, errors.WithStack(err)
+			}
+			sponsor.SponsorshipsCount = 0
+			verrs.Add("sponsorships_count", "You have no invitations left.")
+			return verrs, nil
+		}
+		sponsor.SponsorshipsCount--
+	}
+	return verrs, nil
+}
+
+// consumeSponsorship takes one of the user's sponsorships, if any is left
+func consumeSponsorship(tx *pop.Connection, userID uuid.UUID) (bool, error) {
+	count, err := tx.RawQuery("UPDATE users SET sponsorships_count = sponsorships_count - 1 WHERE id = ? AND sponsorships_count > 0", userID).ExecWithCount()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return count > 0, nil
+}
+
+// PendingInvitations returns the invitations sent by the sponsor not redeemed yet, newest first
+func PendingInvitations(tx *pop.Connection, sponsorID uuid.UUID) (Users, error) {
+	invitations := Users{}
+	err := tx.Where("sponsor_id = ? AND invitation_token <> '' AND provider_id IS NULL", sponsorID).
+		Order("invited_at desc").
+		All(&invitations)
+	return invitations, errors.WithStack(err)
+}
+
+// RenewInvitation gives the invitation a new token and a new TTL,
+// links sent before stop working
+func RenewInvitation(tx *pop.Connection, invitee *User) error {
+	token, err := uuid.NewV4()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	invitee.InvitationToken = token.String()
+	invitee.InvitedAt = time.Now()
+	err = tx.RawQuery("UPDATE users SET invitation_token = ?, invited_at = ? WHERE id = ?", invitee.InvitationToken, invitee.InvitedAt, invitee.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// RevokeInvitation deletes a pending invitation and refunds its sponsor,
+// if the invitation used up a sponsorship
+func RevokeInvitation(tx *pop.Connection, invitee *User) error {
+	if !invitee.InvitationPending() {
+		return errors.New("invitation was already redeemed")
+	}
+	if err := tx.Destroy(invitee); err != nil {
+		return errors.WithStack(err)
+	}
+	if !invitee.SponsorshipUsed {
+		return nil
+	}
+	return GrantSponsorships(tx, invitee.SponsorID, 1)
+}
+
+// ExpireInvitations revokes the pending invitations past their TTL,
+// refunding their sponsors. Returns how many expired.
+func ExpireInvitations(tx *pop.Connection) (int, error) {
+	expired := Users{}
+	err := tx.Where("invitation_token <> '' AND provider_id IS NULL AND invited_at < ?", time.Now().Add(-InvitationTTL)).
+		All(&expired)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	for i := range expired {
+		if err := RevokeInvitation(tx, &expired[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// GrantSponsorships adds count invitations to the user's quota, or takes them back
+// if count is negative. The quota never goes below 0.
+func GrantSponsorships(tx *pop.Connection, userID uuid.UUID, count int) error {
+	err := tx.RawQuery("UPDATE users SET sponsorships_count = CASE WHEN sponsorships_count + ? < 0 THEN 0 ELSE sponsorships_count + ? END WHERE id = ?", count, count, userID).Exec()
+	return errors.WithStack(err)
+}
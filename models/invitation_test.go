@@ -0,0 +1,162 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Invitation_InviteUser() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	verrs, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.NotEmpty(invitee.InvitationToken)
+	ms.True(invitee.InvitationPending())
+	ms.False(invitee.InvitationExpired())
+
+	// the sponsorship is consumed, in the db too
+	ms.Equal(0, sponsor.SponsorshipsCount)
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(0, sponsor.SponsorshipsCount)
+	ms.False(sponsor.CanInvite())
+
+	verrs, err = models.InviteUser(ms.DB, sponsor, &models.User{Email: nulls.NewString("other@example.com")})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	pending, err := models.PendingInvitations(ms.DB, sponsor.ID)
+	ms.NoError(err)
+	ms.Len(pending, 1)
+	ms.Equal(invitee.ID, pending[0].ID)
+}
+
+func (ms *ModelSuite) Test_Invitation_InviteUser_StaleSponsor() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+
+	// two requests loaded the sponsor with its last sponsorship
+	first, second := *sponsor, *sponsor
+	verrs, err := models.InviteUser(ms.DB, &first, &models.User{Email: nulls.NewString("friend@example.com")})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	verrs, err = models.InviteUser(ms.DB, &second, &models.User{Email: nulls.NewString("other@example.com")})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	ms.Equal(0, second.SponsorshipsCount)
+
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(0, sponsor.SponsorshipsCount)
+	pending, err := models.PendingInvitations(ms.DB, sponsor.ID)
+	ms.NoError(err)
+	ms.Len(pending, 1)
+	count, err := ms.DB.Where("email = ?", "other@example.com").Count("users")
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_Invitation_AdminsDontCount() {
+	admin := &models.User{IsAdmin: true}
+	ms.NoError(ms.DB.Create(admin))
+
+	verrs, err := models.InviteUser(ms.DB, admin, &models.User{Email: nulls.NewString("friend@example.com")})
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.NoError(ms.DB.Reload(admin))
+	ms.Equal(0, admin.SponsorshipsCount)
+}
+
+func (ms *ModelSuite) Test_Invitation_RevokeRefunds() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	_, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+
+	ms.NoError(models.RevokeInvitation(ms.DB, invitee))
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(1, sponsor.SponsorshipsCount)
+	count, err := ms.DB.Where("id = ?", invitee.ID).Count("users")
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	// redeemed invitations are accounts now, they can't be revoked
+	member := &models.User{ProviderID: nulls.NewString("42"), SponsorID: sponsor.ID}
+	ms.NoError(ms.DB.Create(member))
+	ms.Error(models.RevokeInvitation(ms.DB, member))
+}
+
+func (ms *ModelSuite) Test_Invitation_Renew() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	_, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+	oldToken := invitee.InvitationToken
+
+	ms.NoError(models.RenewInvitation(ms.DB, invitee))
+	newToken := invitee.InvitationToken
+	ms.NotEqual(oldToken, newToken)
+	ms.NoError(ms.DB.Reload(invitee))
+	ms.Equal(newToken, invitee.InvitationToken)
+	count, err := ms.DB.Where("invitation_token = ?", oldToken).Count("users")
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_Invitation_ExpireInvitations() {
+	sponsor := &models.User{}
+	ms.NoError(ms.DB.Create(sponsor))
+	stale := &models.User{Email: nulls.NewString("stale@example.com"), InvitationToken: "stale", InvitedAt: time.Now().Add(-models.InvitationTTL - time.Hour), SponsorID: sponsor.ID}
+	fresh := &models.User{Email: nulls.NewString("fresh@example.com"), InvitationToken: "fresh", InvitedAt: time.Now(), SponsorID: sponsor.ID}
+	ms.NoError(ms.DB.Create(stale))
+	ms.NoError(ms.DB.Create(fresh))
+	ms.True(stale.InvitationExpired())
+
+	count, err := models.ExpireInvitations(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	pending, err := models.PendingInvitations(ms.DB, sponsor.ID)
+	ms.NoError(err)
+	ms.Len(pending, 1)
+	ms.Equal(fresh.ID, pending[0].ID)
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(1, sponsor.SponsorshipsCount)
+}
+
+func (ms *ModelSuite) Test_Invitation_GrantSponsorships() {
+	user := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(user))
+
+	ms.NoError(models.GrantSponsorships(ms.DB, user.ID, 3))
+	ms.NoError(ms.DB.Reload(user))
+	ms.Equal(4, user.SponsorshipsCount)
+
+	// never below zero
+	ms.NoError(models.GrantSponsorships(ms.DB, user.ID, -10))
+	ms.NoError(ms.DB.Reload(user))
+	ms.Equal(0, user.SponsorshipsCount)
+}
+
+func (ms *ModelSuite) Test_Invitation_GetsDigests() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	_, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+	ms.NoError(ms.DB.Reload(invitee))
+	ms.Equal(models.DigestWeekly, invitee.DigestFrequency)
+
+	// once signed up, she gets the weekly digest
+	ms.NoError(ms.DB.RawQuery("UPDATE users SET provider_id = ? WHERE id = ?", "42", invitee.ID).Exec())
+	due, err := models.DueForDigest(ms.DB, models.DigestWeekly, time.Now())
+	ms.NoError(err)
+	ms.Len(due, 1)
+	ms.Equal(invitee.ID, due[0].ID)
+}
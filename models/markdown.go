@@ -0,0 +1,163 @@
+package models
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// frontMatterDelimiter opens and closes the YAML front matter of a Markdown file
+const frontMatterDelimiter = "---"
+
+// FrontMatter is the YAML header of a text saved as a Markdown file,
+// the content follows it. Dates are RFC 3339 when exported, Date is
+// what most blog engines use and is only read, see ParseMarkdown.
+type FrontMatter struct {
+	ID          string          `yaml:"id,omitempty"`
+	Title       string          `yaml:"title"`
+	Date        string          `yaml:"date,omitempty"`
+	Draft       bool            `yaml:"draft"`
+	CreatedAt   string          `yaml:"created_at,omitempty"`
+	PublishedAt string          `yaml:"published_at,omitempty"`
+	Tags        FrontMatterTags `yaml:"tags,omitempty"`
+}
+
+// FrontMatterTags reads tags written as a YAML list or as a comma separated string
+type FrontMatterTags []string
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (t *FrontMatterTags) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*t = list
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	*t = strings.Split(s, ",")
+	return nil
+}
+
+// frontMatterDates are the date formats read in front matter, most specific first
+var frontMatterDates = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseFrontMatterDate reads a date as written in front matter
+func parseFrontMatterDate(s string) (time.Time, error) {
+	for _, layout := range frontMatterDates {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("unreadable date %q", s)
+}
+
+// Markdown returns the text as a Markdown file with its front matter,
+// its Tags need to be loaded
+func (t Text) Markdown() ([]byte, error) {
+	fm := FrontMatter{
+		ID:        t.ID.String(),
+		Title:     t.Title,
+		Draft:     t.Draft,
+		CreatedAt: t.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if t.PublishedAt.Valid {
+		fm.PublishedAt = t.PublishedAt.Time.UTC().Format(time.RFC3339)
+	}
+	for _, tag := range t.Tags {
+		fm.Tags = append(fm.Tags, tag.Name)
+	}
+
+	header, err := yaml.Marshal(fm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(frontMatterDelimiter + "\n")
+	b.Write(header)
+	b.WriteString(frontMatterDelimiter + "\n\n")
+	b.WriteString(t.Content)
+	if t.Content != "" && t.Content[len(t.Content)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// ParseMarkdown reads a Markdown file, with or without front matter, into an unsaved draft.
+// The title is taken from the front matter, else from a leading "# " heading,
+// else from the file name. The returned date is the one of the front matter,
+// zero if it has none. Imported texts are drafts whatever their front matter says,
+// so that they only count against the posting quota once published.
+func ParseMarkdown(name string, data []byte) (*Text, time.Time, error) {
+	var date time.Time
+	content := strings.Replace(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n", -1)
+
+	fm := FrontMatter{}
+	if strings.HasPrefix(content, frontMatterDelimiter+"\n") {
+		lines := strings.SplitAfter(content, "\n")
+		end := -1
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimRight(lines[i], " \n") == frontMatterDelimiter {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, date, errors.New("front matter isn't closed by ---")
+		}
+		if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "")), &fm); err != nil {
+			return nil, date, errors.Wrap(err, "invalid front matter")
+		}
+		content = strings.Join(lines[end+1:], "")
+	}
+
+	for _, d := range []string{fm.Date, fm.PublishedAt, fm.CreatedAt} {
+		if d == "" {
+			continue
+		}
+		var err error
+		if date, err = parseFrontMatterDate(d); err != nil {
+			return nil, date, err
+		}
+		break
+	}
+
+	content = strings.TrimLeft(content, "\n")
+	title := strings.TrimSpace(fm.Title)
+	if title == "" && strings.HasPrefix(content, "# ") {
+		line := content
+		if i := strings.Index(content, "\n"); i >= 0 {
+			line = content[:i]
+		}
+		title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		content = strings.TrimLeft(content[len(line):], "\n")
+	}
+	if title == "" {
+		base := path.Base(name)
+		title = strings.TrimSpace(strings.TrimSuffix(base, path.Ext(base)))
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, date, errors.New("the text is empty")
+	}
+
+	text := &Text{
+		Title:   title,
+		Content: strings.TrimRight(content, "\n") + "\n",
+		Draft:   true,
+		Tags:    ParseTags(strings.Join(fm.Tags, ",")),
+	}
+	return text, date, nil
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// what a notification is about
+const (
+	NotificationStarred   = "starred"   // Actor starred the user's Text
+	NotificationSignedUp  = "signed_up" // Actor, invited by the user, signed up
+	NotificationMentioned = "mentioned" // Actor mentioned the user in Text
+)
+
+// NotificationKinds are the kinds of notifications we know how to display
+var NotificationKinds = []string{NotificationStarred, NotificationSignedUp, NotificationMentioned}
+
+// NotificationRetention is how long read notifications are kept before
+// PruneNotifications deletes them. Defaults to 30 days, set NOTIFICATIONS_RETENTION
+// (e.g. "720h") to change it.
+var NotificationRetention = 30 * 24 * time.Hour
+
+func init() {
+	if d, err := time.ParseDuration(envy.Get("NOTIFICATIONS_RETENTION", "720h")); err == nil && d > 0 {
+		NotificationRetention = d
+	} else {
+		log.Printf("invalid NOTIFICATIONS_RETENTION, using %s", NotificationRetention)
+	}
+}
+
+// Notification tells a user something happened that concerns her
+type Notification struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	ActorID   uuid.UUID  `json:"actor_id" db:"actor_id"`
+	Actor     User       `json:"-" db:"-"`
+	Kind      string     `json:"kind" db:"kind"`
+	TextID    nulls.UUID `json:"text_id" db:"text_id"`
+	Text      Text       `json:"-" db:"-"`
+	ReadAt    nulls.Time `json:"read_at" db:"read_at"`
+}
+
+// String is not required by pop and may be deleted
+func (n Notification) String() string {
+	jn, _ := json.Marshal(n)
+	return string(jn)
+}
+
+// Notifications is not required by pop and may be deleted
+type Notifications []Notification
+
+// String is not required by pop and may be deleted
+func (n Notifications) String() string {
+	jn, _ := json.Marshal(n)
+	return string(jn)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (n *Notification) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: n.UserID, Name: "UserID"},
+		&validators.UUIDIsPresent{Field: n.ActorID, Name: "ActorID"},
+		&validators.StringInclusion{Field: n.Kind, Name: "Kind", List: NotificationKinds},
+	), nil
+}
+
+// Read checks if the user has seen the notification
+func (n Notification) Read() bool {
+	return n.ReadAt.Valid
+}
+
+// Deliver passes on each notification Notify saves, e.g. to email it.
+// The web app sets it, see actions.sendNotificationEmail, nil delivers nothing.
+var Deliver func(tx *pop.Connection, n *Notification) error
+
+// Notify saves the notification, users aren't notified of their own doings,
+// then hands it to Deliver
+func Notify(tx *pop.Connection, n *Notification) error {
+	if n.UserID == n.ActorID {
+		return nil
+	}
+	verrs, err := tx.ValidateAndCreate(n)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return errors.New(verrs.Error())
+	}
+	if Deliver == nil {
+		return nil
+	}
+	return Deliver(tx, n)
+}
+
+// CountUnread returns the number of notifications the user hasn't read yet
+func CountUnread(tx *pop.Connection, userID uuid.UUID) (int, error) {
+	count, err := tx.Where("user_id = ? AND read_at IS NULL", userID).Count("notifications")
+	return count, errors.WithStack(err)
+}
+
+// MarkRead marks the notification as read, if it belongs to the user
+func MarkRead(tx *pop.Connection, userID, notificationID uuid.UUID) error {
+	err := tx.RawQuery("UPDATE notifications SET read_at = ? WHERE id = ? AND user_id = ? AND read_at IS NULL", time.Now(), notificationID, userID).Exec()
+	return errors.WithStack(err)
+}
+
+// MarkAllRead marks all the user's notifications as read
+func MarkAllRead(tx *pop.Connection, userID uuid.UUID) error {
+	err := tx.RawQuery("UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL", time.Now(), userID).Exec()
+	return errors.WithStack(err)
+}
+
+// PruneNotifications deletes the notifications read more than NotificationRetention ago,
+// unread ones are kept whatever their age. Returns how many were deleted.
+func PruneNotifications(tx *pop.Connection) (int, error) {
+	before := time.Now().Add(-NotificationRetention)
+	count, err := tx.Where("read_at IS NOT NULL AND read_at < ?", before).Count("notifications")
+	if err != nil || count == 0 {
+		return 0, errors.WithStack(err)
+	}
+	err = tx.RawQuery("DELETE FROM notifications WHERE read_at IS NOT NULL AND read_at < ?", before).Exec()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return count, nil
+}
+
+// LoadNotificationDetails fills in the Actor and Text of the notifications,
+// with one query for all actors and one for all texts
+func LoadNotificationDetails(tx *pop.Connection, ns Notifications) error {
+	actorIDs := make([]uuid.UUID, len(ns))
+	textIDs := []interface{}{}
+	for i, n := range ns {
+		actorIDs[i] = n.ActorID
+		if n.TextID.Valid {
+			textIDs = append(textIDs, n.TextID.UUID)
+		}
+	}
+
+	actors, err := usersByID(tx, actorIDs)
+	if err != nil {
+		return err
+	}
+	texts := map[uuid.UUID]Text{}
+	if len(textIDs) > 0 {
+		ts := Texts{}
+		if err := tx.Where("id in (?)", textIDs...).All(&ts); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, t := range ts {
+			texts[t.ID] = t
+		}
+	}
+
+	for i := range ns {
+		ns[i].Actor = actors[ns[i].ActorID]
+		if ns[i].TextID.Valid {
+			ns[i].Text = texts[ns[i].TextID.UUID]
+		}
+	}
+	return nil
+}
+
+// mentionRegexp matches @nickname in texts, nicknames being made of letters, digits, _ and -
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\w@])@([\w-]+)`)
+
+// MentionedUsers returns the signed up users whose @nickname appears in content
+func MentionedUsers(tx *pop.Connection, content string) (Users, error) {
+	users := Users{}
+
+	seen := map[string]bool{}
+	nicks := []interface{}{}
+	for _, m := range mentionRegexp.FindAllStringSubmatch(content, -1) {
+		nick := strings.ToLower(m[1])
+		if !seen[nick] {
+			seen[nick] = true
+			nicks = append(nicks, nick)
+		}
+	}
+	if len(nicks) == 0 {
+		return users, nil
+	}
+
+	err := tx.Where("provider_id IS NOT NULL").Where("lower(nickname) in (?)", nicks...).All(&users)
+	return users, errors.WithStack(err)
+}
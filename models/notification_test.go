@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Notification_Notify() {
+	alice := &models.User{}
+	bob := &models.User{}
+	ms.NoError(ms.DB.Create(alice))
+	ms.NoError(ms.DB.Create(bob))
+
+	ms.NoError(models.Notify(ms.DB, &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: models.NotificationSignedUp}))
+	// no notifying oneself
+	ms.NoError(models.Notify(ms.DB, &models.Notification{UserID: alice.ID, ActorID: alice.ID, Kind: models.NotificationSignedUp}))
+	ms.Error(models.Notify(ms.DB, &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: "unknown"}))
+
+	count, err := models.CountUnread(ms.DB, alice.ID)
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	n := &models.Notification{}
+	ms.NoError(ms.DB.Where("user_id = ?", alice.ID).First(n))
+	// only the recipient can mark it read
+	ms.NoError(models.MarkRead(ms.DB, bob.ID, n.ID))
+	ms.NoError(ms.DB.Reload(n))
+	ms.False(n.Read())
+	ms.NoError(models.MarkRead(ms.DB, alice.ID, n.ID))
+	ms.NoError(ms.DB.Reload(n))
+	ms.True(n.Read())
+
+	ms.NoError(models.Notify(ms.DB, &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: models.NotificationSignedUp}))
+	ms.NoError(models.MarkAllRead(ms.DB, alice.ID))
+	count, err = models.CountUnread(ms.DB, alice.ID)
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
+
+func (ms *ModelSuite) Test_Notification_Prune() {
+	alice := &models.User{}
+	bob := &models.User{}
+	ms.NoError(ms.DB.Create(alice))
+	ms.NoError(ms.DB.Create(bob))
+
+	old := &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: models.NotificationSignedUp, ReadAt: nulls.NewTime(time.Now().Add(-models.NotificationRetention - time.Hour))}
+	recent := &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: models.NotificationSignedUp, ReadAt: nulls.NewTime(time.Now())}
+	unread := &models.Notification{UserID: alice.ID, ActorID: bob.ID, Kind: models.NotificationSignedUp}
+	for _, n := range []*models.Notification{old, recent, unread} {
+		ms.NoError(ms.DB.Create(n))
+	}
+
+	count, err := models.PruneNotifications(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, count)
+	left, err := ms.DB.Where("user_id = ?", alice.ID).Count("notifications")
+	ms.NoError(err)
+	ms.Equal(2, left)
+}
+
+func (ms *ModelSuite) Test_Notification_MentionedUsers() {
+	mina := &models.User{Nickname: nulls.NewString("Mina"), ProviderID: nulls.NewString("1")}
+	invited := &models.User{Nickname: nulls.NewString("ken")}
+	ms.NoError(ms.DB.Create(mina))
+	ms.NoError(ms.DB.Create(invited))
+
+	users, err := models.MentionedUsers(ms.DB, "Walking with @mina and @ken, mail me at me@mina.jp. @mina again")
+	ms.NoError(err)
+	ms.Len(users, 1)
+	ms.Equal(mina.ID, users[0].ID)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop"
+	"github.com/pkg/errors"
+)
+
+// PostingPolicy is how many texts a user may publish over a rolling window of time,
+// TrustedQuota applies to users with PrivilegePostMore
+type PostingPolicy struct {
+	Quota        int
+	TrustedQuota int
+	Window       time.Duration
+}
+
+// Posting is the policy applied to everyone but admins.
+// Defaults to 1 post per 24 hours, 3 with PrivilegePostMore. Set POSTS_QUOTA,
+// POSTS_QUOTA_TRUSTED and POSTS_WINDOW (e.g. "3", "5" and "72h") to change it.
+var Posting = PostingPolicy{Quota: 1, TrustedQuota: 3, Window: 24 * time.Hour}
+
+func init() {
+	if q, err := strconv.Atoi(envy.Get("POSTS_QUOTA", "1")); err == nil && q > 0 {
+		Posting.Quota = q
+	} else {
+		log.Printf("invalid POSTS_QUOTA, using %d", Posting.Quota)
+	}
+	if q, err := strconv.Atoi(envy.Get("POSTS_QUOTA_TRUSTED", "3")); err == nil && q > 0 {
+		Posting.TrustedQuota = q
+	} else {
+		log.Printf("invalid POSTS_QUOTA_TRUSTED, using %d", Posting.TrustedQuota)
+	}
+	if w, err := time.ParseDuration(envy.Get("POSTS_WINDOW", "24h")); err == nil && w > 0 {
+		Posting.Window = w
+	} else {
+		log.Printf("invalid POSTS_WINDOW, using %s", Posting.Window)
+	}
+}
+
+// QuotaFor returns how many texts the user may publish per window
+func (p PostingPolicy) QuotaFor(u *User) int {
+	if u.Can(PrivilegePostMore) && p.TrustedQuota > p.Quota {
+		return p.TrustedQuota
+	}
+	return p.Quota
+}
+
+// CanPost checks if the user may publish a text right now under the Posting policy.
+// When she can't, the returned duration is how long she has to wait.
+// Admins can always post.
+// Publications are counted from her score history, which keeps them even when
+// the text is unpublished or deleted afterwards, see TextWentLive.
+func (u *User) CanPost(tx *pop.Connection) (bool, time.Duration, error) {
+	if u.IsAdmin {
+		return true, 0, nil
+	}
+	quota := Posting.QuotaFor(u)
+
+	// the texts published during the window, most recent first
+	since := time.Now().Add(-Posting.Window)
+	recent := ScoreEvents{}
+	err := tx.Where("user_id = ? AND reason = ? AND created_at > ?", u.ID, ReasonPosted, since).
+		Order("created_at desc").
+		Limit(quota).
+		All(&recent)
+	if err != nil {
+		return false, 0, errors.WithStack(err)
+	}
+	if len(recent) < quota {
+		return true, 0, nil
+	}
+
+	// a slot frees up when the oldest of those leaves the window
+	oldest := recent[len(recent)-1].CreatedAt
+	return false, time.Until(oldest.Add(Posting.Window)), nil
+}
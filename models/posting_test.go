@@ -0,0 +1,58 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_User_CanPost() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+
+	ok, wait, err := u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.True(ok)
+	ms.Equal(time.Duration(0), wait)
+
+	// drafts don't count, published texts do
+	ms.NoError(ms.DB.Create(&models.Text{Title: "Draft", AuthorID: u.ID, Draft: true}))
+	texts := models.Texts{}
+	for i := 0; i < models.Posting.Quota; i++ {
+		texts = append(texts, *ms.published(u, time.Hour))
+	}
+
+	ok, wait, err = u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.False(ok)
+	ms.InDelta(float64(models.Posting.Window-time.Hour), float64(wait), float64(time.Minute))
+
+	// unpublishing or deleting them gives no slot back
+	for i := range texts {
+		texts[i].Draft = true
+		texts[i].PublishedAt = nulls.Time{}
+		ms.NoError(ms.DB.Update(&texts[i]))
+	}
+	ms.NoError(models.DeleteText(ms.DB, &texts[0]))
+	ok, _, err = u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.False(ok)
+
+	// admins are exempt
+	u.IsAdmin = true
+	ok, _, err = u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.True(ok)
+}
+
+// published creates a text of the user published the given time ago,
+// as TextWentLive would have
+func (ms *ModelSuite) published(u *models.User, ago time.Duration) *models.Text {
+	at := time.Now().Add(-ago)
+	text := &models.Text{Title: "Posted", AuthorID: u.ID, PublishedAt: nulls.NewTime(at)}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(models.TextWentLive(ms.DB, u, text))
+	ms.NoError(ms.DB.RawQuery("UPDATE score_events SET created_at = ? WHERE source_id = ?", at, text.ID).Exec())
+	return text
+}
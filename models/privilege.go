@@ -0,0 +1,71 @@
+package models
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gobuffalo/envy"
+)
+
+// abilities a user unlocks as her score grows
+const (
+	PrivilegeComment        = "comment"
+	PrivilegeFlag           = "flag"
+	PrivilegePostMore       = "post_more"
+	PrivilegeInvite         = "invite"
+	PrivilegeSkipModeration = "skip_moderation"
+)
+
+// Privilege is an ability unlocked once a user's score reaches Threshold
+type Privilege struct {
+	Name        string
+	Description string
+	Threshold   int
+	env         string
+}
+
+// Privileges are the abilities users unlock, lowest threshold first.
+// Set PRIVILEGE_COMMENT, PRIVILEGE_FLAG, PRIVILEGE_POST_MORE, PRIVILEGE_INVITE
+// and PRIVILEGE_SKIP_MODERATION to change the thresholds. Admins have them all.
+var Privileges = []Privilege{
+	{PrivilegeComment, "Comment on texts", 0, "PRIVILEGE_COMMENT"},
+	{PrivilegeFlag, "Flag texts for moderation", 20, "PRIVILEGE_FLAG"},
+	{PrivilegePostMore, "Publish more texts per day", 100, "PRIVILEGE_POST_MORE"},
+	{PrivilegeInvite, "Invite people without using up sponsorships", 200, "PRIVILEGE_INVITE"},
+	{PrivilegeSkipModeration, "Take texts flagged by several trusted users down until an admin reviews them", 500, "PRIVILEGE_SKIP_MODERATION"},
+}
+
+func init() {
+	for i, p := range Privileges {
+		t, err := strconv.Atoi(envy.Get(p.env, strconv.Itoa(p.Threshold)))
+		if err != nil {
+			log.Printf("invalid %s, using %d", p.env, p.Threshold)
+			continue
+		}
+		Privileges[i].Threshold = t
+	}
+}
+
+// PrivilegeThreshold returns the score needed for the privilege
+func PrivilegeThreshold(name string) int {
+	for _, p := range Privileges {
+		if p.Name == name {
+			return p.Threshold
+		}
+	}
+	// unknown privileges are out of reach
+	return int(^uint(0) >> 1)
+}
+
+// Can checks if the user has unlocked the privilege
+func (u *User) Can(privilege string) bool {
+	return u.IsAdmin || u.Score >= PrivilegeThreshold(privilege)
+}
+
+// PointsTo returns how many points the user still needs for the privilege, 0 if she has it
+func (u *User) PointsTo(privilege string) int {
+	if u.Can(privilege) {
+		return 0
+	}
+	return PrivilegeThreshold(privilege) - u.Score
+}
@@ -0,0 +1,72 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Privilege_Can() {
+	threshold := models.PrivilegeThreshold(models.PrivilegeFlag)
+	u := &models.User{Score: threshold - 5}
+	ms.False(u.Can(models.PrivilegeFlag))
+	ms.Equal(5, u.PointsTo(models.PrivilegeFlag))
+
+	u.Score = threshold
+	ms.True(u.Can(models.PrivilegeFlag))
+	ms.Equal(0, u.PointsTo(models.PrivilegeFlag))
+
+	// admins have them all, nobody has unknown ones
+	admin := &models.User{IsAdmin: true, Score: -100}
+	ms.True(admin.Can(models.PrivilegeSkipModeration))
+	ms.False(u.Can("fly"))
+}
+
+func (ms *ModelSuite) Test_Privilege_PostMore() {
+	u := &models.User{Score: models.PrivilegeThreshold(models.PrivilegePostMore)}
+	ms.NoError(ms.DB.Create(u))
+	ms.Equal(models.Posting.TrustedQuota, models.Posting.QuotaFor(u))
+
+	for i := 0; i < models.Posting.Quota; i++ {
+		ms.published(u, time.Hour)
+	}
+	ok, _, err := u.CanPost(ms.DB)
+	ms.NoError(err)
+	ms.Equal(models.Posting.TrustedQuota > models.Posting.Quota, ok)
+}
+
+func (ms *ModelSuite) Test_Privilege_InviteFreely() {
+	sponsor := &models.User{Score: models.PrivilegeThreshold(models.PrivilegeInvite)}
+	ms.NoError(ms.DB.Create(sponsor))
+	ms.True(sponsor.CanInvite())
+
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	verrs, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+
+	ms.False(invitee.SponsorshipUsed)
+
+	// nothing consumed, so nothing refunded either, even once the privilege is lost
+	ms.NoError(ms.DB.RawQuery("UPDATE users SET score = 0 WHERE id = ?", sponsor.ID).Exec())
+	ms.NoError(models.RevokeInvitation(ms.DB, invitee))
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(0, sponsor.SponsorshipsCount)
+}
+
+func (ms *ModelSuite) Test_Privilege_InviteFreely_RefundsEarlierInvitations() {
+	sponsor := &models.User{SponsorshipsCount: 1}
+	ms.NoError(ms.DB.Create(sponsor))
+	invitee := &models.User{Email: nulls.NewString("friend@example.com")}
+	verrs, err := models.InviteUser(ms.DB, sponsor, invitee)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.True(invitee.SponsorshipUsed)
+
+	// the sponsorship was used up before the privilege was unlocked, it comes back
+	ms.NoError(ms.DB.RawQuery("UPDATE users SET score = ? WHERE id = ?", models.PrivilegeThreshold(models.PrivilegeInvite), sponsor.ID).Exec())
+	ms.NoError(models.RevokeInvitation(ms.DB, invitee))
+	ms.NoError(ms.DB.Reload(sponsor))
+	ms.Equal(1, sponsor.SponsorshipsCount)
+}
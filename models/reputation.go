@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// A user's Score is the sum of her ScoreEvents: every change goes through
+// addScore, which records the event and updates the score in the same transaction.
+// RecomputeScores rebuilds the scores from the ledger if they ever drift.
+
+// why a user's score changed
+const (
+	ReasonOpeningBalance = "opening_balance" // score she had before the ledger existed
+	ReasonSignedUp       = "signed_up"
+	ReasonLoggedIn       = "logged_in"
+	ReasonAway           = "away" // days since she last logged in
+	ReasonPosted         = "posted"
+	ReasonPostDeleted    = "post_deleted"
+	ReasonStarred        = "starred"
+	ReasonUnstarred      = "unstarred"
+	ReasonFlagged        = "flagged"
+	ReasonCommented      = "commented"
+	ReasonCommentDeleted = "comment_deleted"
+)
+
+// what a score event is about
+const (
+	SourceText    = "text"
+	SourceComment = "comment"
+)
+
+// ScoreEvent is one change of a user's score, with its reason and what caused it
+type ScoreEvent struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Points     int        `json:"points" db:"points"`
+	Reason     string     `json:"reason" db:"reason"`
+	SourceType string     `json:"source_type" db:"source_type"`
+	SourceID   nulls.UUID `json:"source_id" db:"source_id"`
+}
+
+// String is not required by pop and may be deleted
+func (e ScoreEvent) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// ScoreEvents is not required by pop and may be deleted
+type ScoreEvents []ScoreEvent
+
+// String is not required by pop and may be deleted
+func (e ScoreEvents) String() string {
+	je, _ := json.Marshal(e)
+	return string(je)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (e *ScoreEvent) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: e.Reason, Name: "Reason"},
+	), nil
+}
+
+// scoreReasons describe the reasons on the profile page
+var scoreReasons = map[string]string{
+	ReasonOpeningBalance: "Score before the history was kept",
+	ReasonSignedUp:       "Joined Kumano",
+	ReasonLoggedIn:       "Came back",
+	ReasonAway:           "Days away",
+	ReasonPosted:         "Published a text",
+	ReasonPostDeleted:    "Deleted a text",
+	ReasonStarred:        "Text starred",
+	ReasonUnstarred:      "Text unstarred",
+	ReasonFlagged:        "Text taken down after a flag",
+	ReasonCommented:      "Commented",
+	ReasonCommentDeleted: "Deleted a comment",
+}
+
+// Description is what the event is, in plain words
+func (e ScoreEvent) Description() string {
+	if d, ok := scoreReasons[e.Reason]; ok {
+		return d
+	}
+	return e.Reason
+}
+
+// addScore records the event and changes the user's score in place in the DB
+// so we don't overwrite changes made concurrently.
+// sourceType and sourceID tell what caused it, they may be "" and uuid.Nil.
+func addScore(tx *pop.Connection, userID uuid.UUID, points int, reason, sourceType string, sourceID uuid.UUID) error {
+	e := &ScoreEvent{
+		UserID:     userID,
+		Points:     points,
+		Reason:     reason,
+		SourceType: sourceType,
+	}
+	if sourceID != uuid.Nil {
+		e.SourceID = nulls.NewUUID(sourceID)
+	}
+	verrs, err := tx.ValidateAndCreate(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return errors.New(verrs.Error())
+	}
+
+	err = tx.RawQuery("UPDATE users SET score = score + ? WHERE id = ?", points, userID).Exec()
+	return errors.WithStack(err)
+}
+
+// CreditSignUp gives a new user her PointsCreatesAccount
+func CreditSignUp(tx *pop.Connection, u *User) error {
+	if err := addScore(tx, u.ID, PointsCreatesAccount, ReasonSignedUp, "", uuid.Nil); err != nil {
+		return err
+	}
+	u.Score += PointsCreatesAccount
+	return nil
+}
+
+// CreditLogIn gives the user PointsLogsIn for coming back, minus PointsPerDayAway
+// for every full day since she last logged in, and records when she logged in
+func CreditLogIn(tx *pop.Connection, u *User, at time.Time) error {
+	if days := int(at.Sub(u.LastLoggedAt) / (24 * time.Hour)); days > 0 && !u.LastLoggedAt.IsZero() {
+		if err := addScore(tx, u.ID, days*PointsPerDayAway, ReasonAway, "", uuid.Nil); err != nil {
+			return err
+		}
+		u.Score += days * PointsPerDayAway
+	}
+	if err := addScore(tx, u.ID, PointsLogsIn, ReasonLoggedIn, "", uuid.Nil); err != nil {
+		return err
+	}
+	u.Score += PointsLogsIn
+
+	u.LastLoggedAt = at
+	err := tx.RawQuery("UPDATE users SET last_logged_at = ? WHERE id = ?", at, u.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// ScoreHistory returns the query for the user's score events, newest first,
+// paginated with the params
+func ScoreHistory(tx *pop.Connection, userID uuid.UUID, params pop.PaginationParams) *pop.Query {
+	return tx.PaginateFromParams(params).Where("user_id = ?", userID).Order("created_at desc")
+}
+
+// RecomputeScores sets every user's score to the sum of her score events.
+// Returns how many scores had drifted from the ledger.
+func RecomputeScores(tx *pop.Connection) (int, error) {
+	const ledger = "COALESCE((SELECT SUM(points) FROM score_events WHERE score_events.user_id = users.id), 0)"
+
+	drifted, err := tx.Where("score <> " + ledger).Count("users")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if drifted == 0 {
+		return 0, nil
+	}
+
+	err = tx.RawQuery("UPDATE users SET score = " + ledger).Exec()
+	return drifted, errors.WithStack(err)
+}
@@ -0,0 +1,72 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Reputation_Ledger() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	text := &models.Text{Title: "Kumano Kodo", Content: "A walk", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+
+	_, err := models.StarText(ms.DB, uuid.Must(uuid.NewV4()), text)
+	ms.NoError(err)
+	ms.NoError(models.CreditPost(ms.DB, author.ID, text.ID, time.Now()))
+
+	events := models.ScoreEvents{}
+	ms.NoError(models.ScoreHistory(ms.DB, author.ID, params{}).All(&events))
+	ms.Len(events, 2)
+	reasons := map[string]int{}
+	for _, e := range events {
+		ms.Equal(text.ID, e.SourceID.UUID)
+		ms.Equal(models.SourceText, e.SourceType)
+		reasons[e.Reason] = e.Points
+	}
+	ms.Equal(models.PointsTextStarred, reasons[models.ReasonStarred])
+	ms.Equal(models.PointsPosts, reasons[models.ReasonPosted])
+
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(models.PointsTextStarred+models.PointsPosts, author.Score)
+}
+
+func (ms *ModelSuite) Test_Reputation_CreditLogIn() {
+	now := time.Now()
+	user := &models.User{LastLoggedAt: now.Add(-3*24*time.Hour - time.Hour)}
+	ms.NoError(ms.DB.Create(user))
+
+	ms.NoError(models.CreditLogIn(ms.DB, user, now))
+	want := 3*models.PointsPerDayAway + models.PointsLogsIn
+	ms.Equal(want, user.Score)
+
+	ms.NoError(ms.DB.Reload(user))
+	ms.Equal(want, user.Score)
+	ms.WithinDuration(now, user.LastLoggedAt, time.Second)
+
+	// back the same day, no decay
+	ms.NoError(models.CreditLogIn(ms.DB, user, now.Add(time.Hour)))
+	ms.NoError(ms.DB.Reload(user))
+	ms.Equal(want+models.PointsLogsIn, user.Score)
+}
+
+func (ms *ModelSuite) Test_Reputation_RecomputeScores() {
+	user := &models.User{}
+	ms.NoError(ms.DB.Create(user))
+	ms.NoError(models.CreditSignUp(ms.DB, user))
+
+	// nothing to fix
+	count, err := models.RecomputeScores(ms.DB)
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	// scores changed behind the ledger's back are put right
+	ms.NoError(ms.DB.RawQuery("UPDATE users SET score = 1000 WHERE id = ?", user.ID).Exec())
+	count, err = models.RecomputeScores(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, count)
+	ms.NoError(ms.DB.Reload(user))
+	ms.Equal(models.PointsCreatesAccount, user.Score)
+}
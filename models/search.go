@@ -0,0 +1,221 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/pkg/errors"
+)
+
+// Searching is done by Postgres full-text search on the search_vector columns
+// of texts and users, which triggers keep up to date (see migrations).
+// Other databases, e.g. SQLite in tests, fall back on matching in Go.
+
+// only published, visible texts and signed up users can be found
+const (
+	searchableTexts = "draft = ? AND hidden = ?"
+	searchableUsers = "provider_id IS NOT NULL"
+)
+
+// searchHit is a row id from a ranked search query
+type searchHit struct {
+	ID uuid.UUID `db:"id"`
+}
+
+// SearchTexts finds the published texts matching all the words in query,
+// best matches first, and returns the requested page of them
+func SearchTexts(tx *pop.Connection, query string, page, perPage int) (Texts, *pop.Paginator, error) {
+	p := pop.NewPaginator(page, perPage)
+	texts := Texts{}
+
+	var ids []uuid.UUID
+	var total int
+	var err error
+	if tx.Dialect.Name() == "postgres" {
+		ids, total, err = searchVector(tx, "texts", searchableTexts+" AND ", []interface{}{false, false}, "published_at desc", query, p)
+	} else {
+		ids, total, err = searchTextsInGo(tx, query, p)
+	}
+	if err != nil {
+		return texts, p, err
+	}
+	setTotal(p, total, len(ids))
+	if len(ids) == 0 {
+		return texts, p, nil
+	}
+
+	if err := tx.Eager().Where("id in (?)", uuidArgs(ids)...).All(&texts); err != nil {
+		return texts, p, errors.WithStack(err)
+	}
+	rank := ranks(ids)
+	sort.Slice(texts, func(i, j int) bool { return rank[texts[i].ID] < rank[texts[j].ID] })
+	return texts, p, nil
+}
+
+// SearchUsers finds the users whose name, nickname or bio match
+// all the words in query, best matches first
+func SearchUsers(tx *pop.Connection, query string, page, perPage int) (Users, *pop.Paginator, error) {
+	p := pop.NewPaginator(page, perPage)
+	users := Users{}
+
+	var ids []uuid.UUID
+	var total int
+	var err error
+	if tx.Dialect.Name() == "postgres" {
+		ids, total, err = searchVector(tx, "users", searchableUsers+" AND ", nil, "score desc", query, p)
+	} else {
+		ids, total, err = searchUsersInGo(tx, query, p)
+	}
+	if err != nil {
+		return users, p, err
+	}
+	setTotal(p, total, len(ids))
+	if len(ids) == 0 {
+		return users, p, nil
+	}
+
+	if err := tx.Where("id in (?)", uuidArgs(ids)...).All(&users); err != nil {
+		return users, p, errors.WithStack(err)
+	}
+	rank := ranks(ids)
+	sort.Slice(users, func(i, j int) bool { return rank[users[i].ID] < rank[users[j].ID] })
+	return users, p, nil
+}
+
+// searchVector ranks the rows of table against query with Postgres full-text search,
+// returning the ids of the requested page and the total number of matches
+func searchVector(tx *pop.Connection, table, where string, args []interface{}, tiebreak, query string, p *pop.Paginator) ([]uuid.UUID, int, error) {
+	match := where + "search_vector @@ plainto_tsquery('simple', ?)"
+	args = append(args, query)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := tx.RawQuery("SELECT count(*) AS count FROM "+table+" WHERE "+match, args...).First(&count); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	if count.Count == 0 {
+		return nil, 0, nil
+	}
+
+	hits := []searchHit{}
+	sql := "SELECT id FROM " + table + " WHERE " + match +
+		" ORDER BY ts_rank(search_vector, plainto_tsquery('simple', ?)) DESC, " + tiebreak +
+		" LIMIT ? OFFSET ?"
+	args = append(args, query, p.PerPage, p.Offset)
+	if err := tx.RawQuery(sql, args...).All(&hits); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	ids := make([]uuid.UUID, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	return ids, count.Count, nil
+}
+
+// searchTextsInGo is the fallback for databases without full-text search
+func searchTextsInGo(tx *pop.Connection, query string, p *pop.Paginator) ([]uuid.UUID, int, error) {
+	texts := Texts{}
+	if err := tx.Where(searchableTexts, false, false).Order("published_at desc").All(&texts); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	scored := []scoredHit{}
+	for _, t := range texts {
+		if s := matchScore(query, weighted{t.Title, 4}, weighted{t.Content, 1}); s > 0 {
+			scored = append(scored, scoredHit{t.ID, s})
+		}
+	}
+	return pageHits(scored, p), len(scored), nil
+}
+
+// searchUsersInGo is the fallback for databases without full-text search
+func searchUsersInGo(tx *pop.Connection, query string, p *pop.Paginator) ([]uuid.UUID, int, error) {
+	users := Users{}
+	if err := tx.Where(searchableUsers).Order("score desc").All(&users); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	scored := []scoredHit{}
+	for _, u := range users {
+		s := matchScore(query, weighted{u.Name.String, 4}, weighted{u.Nickname.String, 4}, weighted{u.Bio.String, 1})
+		if s > 0 {
+			scored = append(scored, scoredHit{u.ID, s})
+		}
+	}
+	return pageHits(scored, p), len(scored), nil
+}
+
+// weighted is a searchable field and how much a match on it counts
+type weighted struct {
+	text   string
+	weight int
+}
+
+type scoredHit struct {
+	id    uuid.UUID
+	score int
+}
+
+// matchScore counts the weighted occurrences of each word of query in fields,
+// like plainto_tsquery every word has to match, otherwise the score is 0
+func matchScore(query string, fields ...weighted) int {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return 0
+	}
+
+	score := 0
+	for _, w := range words {
+		found := 0
+		for _, f := range fields {
+			found += strings.Count(strings.ToLower(f.text), w) * f.weight
+		}
+		if found == 0 {
+			return 0
+		}
+		score += found
+	}
+	return score
+}
+
+// pageHits sorts hits by score, keeping the incoming order for ties,
+// and returns the ids on the paginator's page
+func pageHits(hits []scoredHit, p *pop.Paginator) []uuid.UUID {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	ids := []uuid.UUID{}
+	for i := p.Offset; i < len(hits) && i < p.Offset+p.PerPage; i++ {
+		ids = append(ids, hits[i].id)
+	}
+	return ids
+}
+
+// setTotal fills in the paginator like pop does for paginated queries
+func setTotal(p *pop.Paginator, total, current int) {
+	p.TotalEntriesSize = total
+	p.CurrentEntriesSize = current
+	p.TotalPages = total / p.PerPage
+	if total%p.PerPage > 0 {
+		p.TotalPages++
+	}
+}
+
+func ranks(ids []uuid.UUID) map[uuid.UUID]int {
+	rank := make(map[uuid.UUID]int, len(ids))
+	for i, id := range ids {
+		rank[id] = i
+	}
+	return rank
+}
+
+func uuidArgs(ids []uuid.UUID) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Search_Texts() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+
+	inTitle := &models.Text{Title: "Pilgrimage to Kumano", Content: "Three shrines", AuthorID: author.ID}
+	inContent := &models.Text{Title: "Walking", Content: "The old road to kumano", AuthorID: author.ID}
+	draft := &models.Text{Title: "Kumano draft", AuthorID: author.ID, Draft: true}
+	hidden := &models.Text{Title: "Kumano spam", AuthorID: author.ID, Hidden: true}
+	other := &models.Text{Title: "Nakahechi", Content: "Another road", AuthorID: author.ID}
+	for _, t := range []*models.Text{inTitle, inContent, draft, hidden, other} {
+		ms.NoError(ms.DB.Create(t))
+	}
+
+	texts, p, err := models.SearchTexts(ms.DB, "Kumano", 1, 20)
+	ms.NoError(err)
+	ms.Equal(2, p.TotalEntriesSize)
+	ms.Len(texts, 2)
+	// a match in the title ranks higher
+	ms.Equal(inTitle.ID, texts[0].ID)
+	ms.Equal(inContent.ID, texts[1].ID)
+
+	// every word has to match
+	texts, _, err = models.SearchTexts(ms.DB, "kumano road", 1, 20)
+	ms.NoError(err)
+	ms.Len(texts, 1)
+	ms.Equal(inContent.ID, texts[0].ID)
+
+	// paginated
+	texts, p, err = models.SearchTexts(ms.DB, "kumano", 2, 1)
+	ms.NoError(err)
+	ms.Equal(2, p.TotalPages)
+	ms.Len(texts, 1)
+	ms.Equal(inContent.ID, texts[0].ID)
+}
+
+func (ms *ModelSuite) Test_Search_Users() {
+	hiker := &models.User{Name: nulls.NewString("Mina"), Nickname: nulls.NewString("mina"), Bio: nulls.NewString("I hike the Kumano Kodo"), ProviderID: nulls.NewString("1")}
+	invited := &models.User{Name: nulls.NewString("Kumano fan")}
+	ms.NoError(ms.DB.Create(hiker))
+	ms.NoError(ms.DB.Create(invited))
+
+	users, p, err := models.SearchUsers(ms.DB, "kumano", 1, 20)
+	ms.NoError(err)
+	ms.Equal(1, p.TotalEntriesSize)
+	ms.Len(users, 1)
+	ms.Equal(hiker.ID, users[0].ID)
+}
@@ -1,14 +1,17 @@
 package models
 
 import (
+	"database/sql"
 	"encoding/json"
 	"time"
 
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
 )
 
+// Star is a user liking a text, a user stars a given text only once
 type Star struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -39,9 +42,17 @@ func (s *Star) Validate(tx *pop.Connection) (*validate.Errors, error) {
 }
 
 // ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
-// This method is not required and may be deleted.
+// A user stars a given text only once, see also the unique index on stars.
 func (s *Star) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.NewErrors(), nil
+	verrs := validate.NewErrors()
+	starred, err := HasStarred(tx, s.UserID, s.TextID)
+	if err != nil {
+		return verrs, err
+	}
+	if starred {
+		verrs.Add("star", "You already starred this text.")
+	}
+	return verrs, nil
 }
 
 // ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
@@ -49,3 +60,55 @@ func (s *Star) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
 func (s *Star) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.NewErrors(), nil
 }
+
+// HasStarred checks if the user already starred the text
+func HasStarred(tx *pop.Connection, userID, textID uuid.UUID) (bool, error) {
+	exists, err := tx.Where("user_id = ? AND text_id = ?", userID, textID).Exists("stars")
+	return exists, errors.WithStack(err)
+}
+
+// CountStars returns the number of stars a text received
+func CountStars(tx *pop.Connection, textID uuid.UUID) (int, error) {
+	count, err := tx.Where("text_id = ?", textID).Count("stars")
+	return count, errors.WithStack(err)
+}
+
+// StarText stars the text on behalf of the user and gives
+// PointsTextStarred to its author. Starring an already starred text
+// is a no-op, the returned bool tells if a star was actually added.
+func StarText(tx *pop.Connection, userID uuid.UUID, text *Text) (bool, error) {
+	star := &Star{
+		UserID: userID,
+		TextID: text.ID,
+	}
+	verrs, err := tx.ValidateAndCreate(star)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		// already starred
+		return false, nil
+	}
+
+	return true, addScore(tx, text.AuthorID, PointsTextStarred, ReasonStarred, SourceText, text.ID)
+}
+
+// UnstarText removes the user's star from the text and takes back
+// the PointsTextStarred its author got. Unstarring a text that wasn't
+// starred is a no-op, the returned bool tells if a star was actually removed.
+func UnstarText(tx *pop.Connection, userID uuid.UUID, text *Text) (bool, error) {
+	star := &Star{}
+	err := tx.Where("user_id = ? AND text_id = ?", userID, text.ID).First(star)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+
+	if err := tx.Destroy(star); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return true, addScore(tx, text.AuthorID, -PointsTextStarred, ReasonUnstarred, SourceText, text.ID)
+}
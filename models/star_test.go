@@ -1,7 +1,44 @@
 package models_test
 
-import "testing"
+import (
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+)
 
-func Test_Star(t *testing.T) {
-	t.Fatal("This test needs to be implemented!")
+func (ms *ModelSuite) Test_Star_Toggle() {
+	author := &models.User{Score: 10}
+	ms.NoError(ms.DB.Create(author))
+	text := &models.Text{Title: "Kumano Kodo", Content: "A walk", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+	userID := uuid.Must(uuid.NewV4())
+
+	// starring is idempotent
+	added, err := models.StarText(ms.DB, userID, text)
+	ms.NoError(err)
+	ms.True(added)
+	added, err = models.StarText(ms.DB, userID, text)
+	ms.NoError(err)
+	ms.False(added)
+
+	count, err := models.CountStars(ms.DB, text.ID)
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(10+models.PointsTextStarred, author.Score)
+
+	// and so is unstarring
+	removed, err := models.UnstarText(ms.DB, userID, text)
+	ms.NoError(err)
+	ms.True(removed)
+	removed, err = models.UnstarText(ms.DB, userID, text)
+	ms.NoError(err)
+	ms.False(removed)
+
+	count, err = models.CountStars(ms.DB, text.ID)
+	ms.NoError(err)
+	ms.Equal(0, count)
+
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(10, author.Score)
 }
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
+)
+
+// limits on the tags of a text
+const (
+	MaxTagsPerText = 5
+	MaxTagLength   = 30
+)
+
+// Tag groups texts by subject, texts are tagged through the text_tags table.
+// Its Slug is the name lowercased with dashes, it identifies the tag in URLs.
+type Tag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+}
+
+// String is not required by pop and may be deleted
+func (t Tag) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Tags is not required by pop and may be deleted
+type Tags []Tag
+
+// String is not required by pop and may be deleted
+func (t Tags) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Names returns the tag names as typed in the text form, comma separated
+func (t Tags) Names() string {
+	names := make([]string, len(t))
+	for i, tag := range t {
+		names[i] = tag.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (t *Tag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: t.Slug, Name: "Name", Message: "A tag needs letters or digits."},
+		&validators.StringLengthInRange{Field: t.Name, Name: "Name", Max: MaxTagLength},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+func (t *Tag) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return t.slugTaken(tx)
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+func (t *Tag) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return t.slugTaken(tx)
+}
+
+// slugTaken checks no other tag has the slug, see also the unique index on tags
+func (t *Tag) slugTaken(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	exists, err := tx.Where("slug = ? AND id <> ?", t.Slug, t.ID).Exists("tags")
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if exists {
+		verrs.Add("name", "There already is a tag with this name, merge them instead.")
+	}
+	return verrs, nil
+}
+
+// TextTag links a text to one of its tags
+type TextTag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	TextID    uuid.UUID `json:"text_id" db:"text_id"`
+	TagID     uuid.UUID `json:"tag_id" db:"tag_id"`
+}
+
+// Slugify turns a tag name into its slug: lowercase letters and digits,
+// anything else becomes a single dash
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// ParseTags reads the comma separated tags typed in the text form.
+// The tags aren't saved, duplicates and tags without a slug are dropped,
+// and only the first MaxTagsPerText are kept.
+func ParseTags(input string) Tags {
+	tags := Tags{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(input, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		if len([]rune(name)) > MaxTagLength {
+			name = string([]rune(name)[:MaxTagLength])
+		}
+		slug := Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, Tag{Name: name, Slug: slug})
+		if len(tags) == MaxTagsPerText {
+			break
+		}
+	}
+	return tags
+}
+
+// SaveTextTags tags the text with text.Tags, as returned by ParseTags,
+// creating the tags nobody used yet and untagging the ones left out
+func SaveTextTags(tx *pop.Connection, text *Text) error {
+	tags := Tags{}
+	for _, t := range text.Tags {
+		tag := &Tag{}
+		err := tx.Where("slug = ?", t.Slug).First(tag)
+		if err != nil {
+			tag = &Tag{Name: t.Name, Slug: t.Slug}
+			verrs, err := tx.ValidateAndCreate(tag)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if verrs.HasAny() {
+				return errors.New(verrs.Error())
+			}
+		}
+		tags = append(tags, *tag)
+	}
+
+	if err := tx.RawQuery("DELETE FROM text_tags WHERE text_id = ?", text.ID).Exec(); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, tag := range tags {
+		if err := tx.Create(&TextTag{TextID: text.ID, TagID: tag.ID}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	text.Tags = tags
+	return nil
+}
+
+// TaggedTexts returns the query for the published texts with the tag,
+// paginated with the params
+func TaggedTexts(tx *pop.Connection, tagID uuid.UUID, params pop.PaginationParams) *pop.Query {
+	return tx.PaginateFromParams(params).
+		Where("draft = ? AND hidden = ? AND id IN (SELECT text_id FROM text_tags WHERE tag_id = ?)", false, false, tagID).
+		Order("published_at desc")
+}
+
+// SuggestTags returns the tags starting like prefix, most used first, for autocompletion
+func SuggestTags(tx *pop.Connection, prefix string, limit int) (Tags, error) {
+	tags := Tags{}
+	slug := Slugify(prefix)
+	if slug == "" {
+		return tags, nil
+	}
+	err := tx.RawQuery("SELECT tags.* FROM tags WHERE slug LIKE ? "+
+		"ORDER BY (SELECT count(*) FROM text_tags WHERE text_tags.tag_id = tags.id) DESC, name LIMIT ?",
+		slug+"%", limit).All(&tags)
+	return tags, errors.WithStack(err)
+}
+
+// TagUsage is a tag with how many texts it's on, Weight is its size
+// in the tag cloud, from 1 to 5
+type TagUsage struct {
+	ID     uuid.UUID `db:"id"`
+	Name   string    `db:"name"`
+	Slug   string    `db:"slug"`
+	Count  int       `db:"count"`
+	Weight int       `db:"-"`
+}
+
+// TagCloud returns the tags most used on texts published since the given time,
+// sorted by name and weighted by usage
+func TagCloud(tx *pop.Connection, since time.Time, limit int) ([]TagUsage, error) {
+	cloud := []TagUsage{}
+	err := tx.RawQuery("SELECT tags.id, tags.name, tags.slug, count(*) AS count FROM tags "+
+		"JOIN text_tags ON text_tags.tag_id = tags.id JOIN texts ON texts.id = text_tags.text_id "+
+		"WHERE texts.draft = ? AND texts.hidden = ? AND texts.published_at >= ? "+
+		"GROUP BY tags.id, tags.name, tags.slug ORDER BY count DESC, tags.name LIMIT ?",
+		false, false, since, limit).All(&cloud)
+	if err != nil {
+		return cloud, errors.WithStack(err)
+	}
+
+	// spread the counts linearly between the least and most used tags
+	min, max := 0, 0
+	for i, t := range cloud {
+		if i == 0 || t.Count < min {
+			min = t.Count
+		}
+		if t.Count > max {
+			max = t.Count
+		}
+	}
+	for i := range cloud {
+		cloud[i].Weight = 1
+		if max > min {
+			cloud[i].Weight += 4 * (cloud[i].Count - min) / (max - min)
+		}
+	}
+
+	sort.Slice(cloud, func(i, j int) bool { return cloud[i].Slug < cloud[j].Slug })
+	return cloud, nil
+}
+
+// AllTagUsage returns every tag with how many texts, published or not, have it,
+// sorted by name. This is for admins tidying up tags.
+func AllTagUsage(tx *pop.Connection) ([]TagUsage, error) {
+	usage := []TagUsage{}
+	err := tx.RawQuery("SELECT tags.id, tags.name, tags.slug, count(texts.id) AS count FROM tags " +
+		"LEFT JOIN text_tags ON text_tags.tag_id = tags.id LEFT JOIN texts ON texts.id = text_tags.text_id " +
+		"GROUP BY tags.id, tags.name, tags.slug ORDER BY tags.name").All(&usage)
+	return usage, errors.WithStack(err)
+}
+
+// RenameTag gives the tag a new name, and the slug that goes with it.
+// Renaming to the name of another tag fails, merge them instead.
+func RenameTag(tx *pop.Connection, tag *Tag, name string) (*validate.Errors, error) {
+	tag.Name = strings.Join(strings.Fields(name), " ")
+	tag.Slug = Slugify(tag.Name)
+	verrs, err := tx.ValidateAndUpdate(tag)
+	return verrs, errors.WithStack(err)
+}
+
+// MergeTags moves the texts tagged with from over to into, then deletes from
+func MergeTags(tx *pop.Connection, from, into *Tag) error {
+	if from.ID == into.ID {
+		return errors.New("can't merge a tag into itself")
+	}
+
+	// texts that already have both tags only lose from
+	err := tx.RawQuery("DELETE FROM text_tags WHERE tag_id = ? AND text_id IN (SELECT text_id FROM text_tags WHERE tag_id = ?)", from.ID, into.ID).Exec()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := tx.RawQuery("UPDATE text_tags SET tag_id = ? WHERE tag_id = ?", into.ID, from.ID).Exec(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tx.Destroy(from))
+}
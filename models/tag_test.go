@@ -0,0 +1,110 @@
+package models_test
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Slugify() {
+	ms.Equal("trail-running", models.Slugify("  Trail Running! "))
+	ms.Equal("été-2018", models.Slugify("Été / 2018"))
+	ms.Equal("", models.Slugify("#!?"))
+}
+
+func (ms *ModelSuite) Test_ParseTags() {
+	tags := models.ParseTags("Hiking, hiking ,  mountain   huts,, ?!, a, b, c, d")
+	ms.Len(tags, models.MaxTagsPerText)
+	ms.Equal("Hiking", tags[0].Name)
+	ms.Equal("mountain huts", tags[1].Name)
+	ms.Equal("mountain-huts", tags[1].Slug)
+	ms.Equal("Hiking, mountain huts, a, b, c", tags.Names())
+}
+
+func (ms *ModelSuite) Test_SaveTextTags() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+	text := &models.Text{Title: "Walk", AuthorID: u.ID}
+	ms.NoError(ms.DB.Create(text))
+	existing := &models.Tag{Name: "Hiking", Slug: "hiking"}
+	ms.NoError(ms.DB.Create(existing))
+
+	text.Tags = models.ParseTags("hiking, huts")
+	ms.NoError(models.SaveTextTags(ms.DB, text))
+	ms.Equal(existing.ID, text.Tags[0].ID)
+
+	count, err := ms.DB.Count("tags")
+	ms.NoError(err)
+	ms.Equal(2, count)
+
+	// tags left out are removed from the text
+	text.Tags = models.ParseTags("huts")
+	ms.NoError(models.SaveTextTags(ms.DB, text))
+	reloaded := &models.Text{}
+	ms.NoError(ms.DB.Eager("Tags").Find(reloaded, text.ID))
+	ms.Equal("huts", reloaded.Tags.Names())
+}
+
+func (ms *ModelSuite) Test_TagCloud() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+	tag := func(title, tags string, publishedAt time.Time) {
+		text := &models.Text{Title: title, AuthorID: u.ID, PublishedAt: nulls.NewTime(publishedAt)}
+		ms.NoError(ms.DB.Create(text))
+		text.Tags = models.ParseTags(tags)
+		ms.NoError(models.SaveTextTags(ms.DB, text))
+	}
+	tag("One", "hiking, huts", time.Now())
+	tag("Two", "hiking", time.Now())
+	tag("Three", "hiking", time.Now())
+	tag("Old", "skiing", time.Now().AddDate(-1, 0, 0))
+
+	cloud, err := models.TagCloud(ms.DB, time.Now().AddDate(0, 0, -30), 10)
+	ms.NoError(err)
+	ms.Len(cloud, 2)
+	ms.Equal("hiking", cloud[0].Slug)
+	ms.Equal(3, cloud[0].Count)
+	ms.Equal(5, cloud[0].Weight)
+	ms.Equal("huts", cloud[1].Slug)
+	ms.Equal(1, cloud[1].Weight)
+}
+
+func (ms *ModelSuite) Test_RenameTag() {
+	hiking := &models.Tag{Name: "hiking", Slug: "hiking"}
+	huts := &models.Tag{Name: "huts", Slug: "huts"}
+	ms.NoError(ms.DB.Create(hiking))
+	ms.NoError(ms.DB.Create(huts))
+
+	verrs, err := models.RenameTag(ms.DB, hiking, "Day Hikes")
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.Equal("day-hikes", hiking.Slug)
+
+	verrs, err = models.RenameTag(ms.DB, hiking, "Huts")
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+}
+
+func (ms *ModelSuite) Test_MergeTags() {
+	u := &models.User{}
+	ms.NoError(ms.DB.Create(u))
+	both := &models.Text{Title: "Both", AuthorID: u.ID}
+	one := &models.Text{Title: "One", AuthorID: u.ID}
+	ms.NoError(ms.DB.Create(both))
+	ms.NoError(ms.DB.Create(one))
+	both.Tags = models.ParseTags("hike, hiking")
+	ms.NoError(models.SaveTextTags(ms.DB, both))
+	one.Tags = models.ParseTags("hike")
+	ms.NoError(models.SaveTextTags(ms.DB, one))
+	from, into := both.Tags[0], both.Tags[1]
+
+	ms.NoError(models.MergeTags(ms.DB, &from, &into))
+
+	count, err := ms.DB.Where("tag_id = ?", into.ID).Count("text_tags")
+	ms.NoError(err)
+	ms.Equal(2, count)
+	count, err = ms.DB.Where("slug = ?", "hike").Count("tags")
+	ms.NoError(err)
+	ms.Equal(0, count)
+}
@@ -9,20 +9,25 @@ import (
 
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
 )
 
 // Text is the base struct for content on our site
 type Text struct {
-	ID          uuid.UUID  `json:"id" db:"id"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	PublishedAt nulls.Time `json:"published_at" db:"published_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
-	Title       string     `json:"title" db:"title"`
-	Content     string     `json:"content" db:"content"`
-	Author      User       `belongs_to:"user"`
-	AuthorID    uuid.UUID  `json:"author_id" db:"author_id"`
-	Draft       bool       `json:"draft" db:"draft"`
-	StarredBy   Users      `many_to_many:"stars" db:"-"`
+	ID               uuid.UUID  `json:"id" db:"id"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	PublishedAt      nulls.Time `json:"published_at" db:"published_at"`
+	FirstPublishedAt nulls.Time `json:"first_published_at" db:"first_published_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+	Title            string     `json:"title" db:"title"`
+	Content          string     `json:"content" db:"content"`
+	Author           User       `belongs_to:"user"`
+	AuthorID         uuid.UUID  `json:"author_id" db:"author_id"`
+	Draft            bool       `json:"draft" db:"draft"`
+	Hidden           bool       `json:"hidden" db:"hidden"`
+	CommentsLocked   bool       `json:"comments_locked" db:"comments_locked"`
+	StarredBy        Users      `many_to_many:"stars" db:"-"`
+	Tags             Tags       `json:"tags" many_to_many:"text_tags" db:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -57,3 +62,127 @@ func (t *Text) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
 func (t *Text) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.NewErrors(), nil
 }
+
+// Scheduled checks if the text is waiting to be published at a later date.
+// Scheduled texts are drafts with a publication date, until PublishDueTexts promotes them.
+func (t Text) Scheduled() bool {
+	return t.Draft && t.PublishedAt.Valid
+}
+
+// CreditPost gives the author her points for posting the text
+// and records when she last posted
+func CreditPost(tx *pop.Connection, authorID, textID uuid.UUID, at time.Time) error {
+	if err := addScore(tx, authorID, PointsPosts, ReasonPosted, SourceText, textID); err != nil {
+		return err
+	}
+	err := tx.RawQuery("UPDATE users SET last_posted_at = ? WHERE id = ?", at, authorID).Exec()
+	return errors.WithStack(err)
+}
+
+// TextWentLive is called whenever a text gets published: the first time,
+// its author gets her points and the users she mentions are notified.
+// Unpublishing and publishing it again earns nothing and notifies no one.
+func TextWentLive(tx *pop.Connection, author *User, text *Text) error {
+	now := time.Now()
+	first, err := markFirstPublished(tx, text, now)
+	if err != nil || !first {
+		return err
+	}
+	if err := CreditPost(tx, author.ID, text.ID, now); err != nil {
+		return err
+	}
+	author.Score += PointsPosts
+	author.LastPostedAt = now
+	return notifyMentions(tx, text)
+}
+
+// markFirstPublished records when the text was first published,
+// it returns false if it already was before
+func markFirstPublished(tx *pop.Connection, text *Text, at time.Time) (bool, error) {
+	count, err := tx.RawQuery("UPDATE texts SET first_published_at = ? WHERE id = ? AND first_published_at IS NULL", at, text.ID).ExecWithCount()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if count == 0 {
+		return false, nil
+	}
+	text.FirstPublishedAt = nulls.NewTime(at)
+	return true, nil
+}
+
+// DeleteText deletes the text, taking back the points its author got for publishing it
+func DeleteText(tx *pop.Connection, text *Text) error {
+	credited, err := tx.Where("user_id = ? AND reason = ? AND source_id = ?", text.AuthorID, ReasonPosted, text.ID).Exists("score_events")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := tx.Destroy(text); err != nil {
+		return errors.WithStack(err)
+	}
+	if !credited {
+		return nil
+	}
+	return addScore(tx, text.AuthorID, -PointsPosts, ReasonPostDeleted, SourceText, text.ID)
+}
+
+// notifyMentions tells the users @mentioned in a text that just went live
+func notifyMentions(tx *pop.Connection, text *Text) error {
+	users, err := MentionedUsers(tx, text.Title+"\n"+text.Content)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		err := Notify(tx, &Notification{
+			UserID:  u.ID,
+			ActorID: text.AuthorID,
+			Kind:    NotificationMentioned,
+			TextID:  nulls.NewUUID(text.ID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishDueTexts publishes the scheduled texts whose publication date has passed
+// and credits their authors, as if they had just posted them, notifying the users they mention.
+// Texts whose author is over her posting quota are postponed until she can post again.
+func PublishDueTexts(tx *pop.Connection) (Texts, error) {
+	due := Texts{}
+	err := tx.Where("draft = ? AND published_at IS NOT NULL AND published_at <= ?", true, time.Now()).Order("published_at asc").All(&due)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	published := Texts{}
+	for i := range due {
+		author := &User{}
+		if err := tx.Find(author, due[i].AuthorID); err != nil {
+			return published, errors.WithStack(err)
+		}
+		ok, wait, err := author.CanPost(tx)
+		if err != nil {
+			return published, err
+		}
+
+		if !ok {
+			due[i].PublishedAt = nulls.NewTime(time.Now().Add(wait))
+		} else {
+			due[i].Draft = false
+			due[i].PublishedAt = nulls.NewTime(time.Now())
+		}
+		if err := tx.Update(&due[i]); err != nil {
+			return published, errors.WithStack(err)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := TextWentLive(tx, author, &due[i]); err != nil {
+			return published, err
+		}
+		published = append(published, due[i])
+	}
+	return published, nil
+}
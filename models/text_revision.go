@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/uuid"
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+)
+
+// TextRevision is a saved version of a text: every time a text is
+// created or updated, its title and content are kept here along with who saved them
+type TextRevision struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	TextID    uuid.UUID `json:"text_id" db:"text_id"`
+	AuthorID  uuid.UUID `json:"author_id" db:"author_id"`
+	Author    User      `belongs_to:"user" db:"-"`
+	Title     string    `json:"title" db:"title"`
+	Content   string    `json:"content" db:"content"`
+}
+
+// String is not required by pop and may be deleted
+func (t TextRevision) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// TextRevisions is not required by pop and may be deleted
+type TextRevisions []TextRevision
+
+// String is not required by pop and may be deleted
+func (t TextRevisions) String() string {
+	jt, _ := json.Marshal(t)
+	return string(jt)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (t *TextRevision) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// RecordRevision keeps the current version of the text, saved by editorID.
+// Saving a text without changing its title or content doesn't add a revision.
+func RecordRevision(tx *pop.Connection, text *Text, editorID uuid.UUID) error {
+	last := &TextRevision{}
+	err := tx.Where("text_id = ?", text.ID).Order("created_at desc").First(last)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return errors.WithStack(err)
+	}
+	if err == nil && last.Title == text.Title && last.Content == text.Content {
+		return nil
+	}
+
+	rev := &TextRevision{
+		TextID:   text.ID,
+		AuthorID: editorID,
+		Title:    text.Title,
+		Content:  text.Content,
+	}
+	return errors.WithStack(tx.Create(rev))
+}
+
+// Restore puts the revision's title and content back on the text
+// and records it as the latest revision
+func (t *TextRevision) Restore(tx *pop.Connection, text *Text, editorID uuid.UUID) error {
+	text.Title = t.Title
+	text.Content = t.Content
+	if err := tx.Update(text); err != nil {
+		return errors.WithStack(err)
+	}
+	return RecordRevision(tx, text, editorID)
+}
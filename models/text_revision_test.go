@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_RecordRevision() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	editor := &models.User{IsAdmin: true}
+	ms.NoError(ms.DB.Create(editor))
+
+	text := &models.Text{Title: "Kumano", Content: "Kodo", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(models.RecordRevision(ms.DB, text, author.ID))
+
+	// saving it unchanged records nothing
+	ms.NoError(models.RecordRevision(ms.DB, text, author.ID))
+	count, err := ms.DB.Where("text_id = ?", text.ID).Count("text_revisions")
+	ms.NoError(err)
+	ms.Equal(1, count)
+
+	text.Content = "Kodo, the old way"
+	ms.NoError(models.RecordRevision(ms.DB, text, editor.ID))
+	revisions := models.TextRevisions{}
+	ms.NoError(ms.DB.Where("text_id = ?", text.ID).Order("created_at desc").All(&revisions))
+	ms.Len(revisions, 2)
+	ms.Equal("Kodo, the old way", revisions[0].Content)
+	ms.Equal(editor.ID, revisions[0].AuthorID)
+}
+
+func (ms *ModelSuite) Test_TextRevision_Restore() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	text := &models.Text{Title: "Kumano", Content: "Kodo", AuthorID: author.ID}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(models.RecordRevision(ms.DB, text, author.ID))
+	first := &models.TextRevision{}
+	ms.NoError(ms.DB.Where("text_id = ?", text.ID).First(first))
+
+	text.Title = "Nakahechi"
+	text.Content = "Another route"
+	ms.NoError(ms.DB.Update(text))
+	ms.NoError(models.RecordRevision(ms.DB, text, author.ID))
+
+	// restoring puts it back as a new revision, the history stays
+	ms.NoError(first.Restore(ms.DB, text, author.ID))
+	ms.NoError(ms.DB.Reload(text))
+	ms.Equal("Kumano", text.Title)
+	ms.Equal("Kodo", text.Content)
+	count, err := ms.DB.Where("text_id = ?", text.ID).Count("text_revisions")
+	ms.NoError(err)
+	ms.Equal(3, count)
+}
@@ -1,7 +1,95 @@
 package models_test
 
-import "testing"
+import (
+	"time"
 
-func Test_Text(t *testing.T) {
-	t.Fatal("This test needs to be implemented!")
+	"github.com/gobuffalo/pop/nulls"
+	"github.com/nicomo/kumano/models"
+)
+
+func (ms *ModelSuite) Test_Text_PublishDueTexts() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	friend := &models.User{Nickname: nulls.NewString("friend"), ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(friend))
+
+	due := &models.Text{Title: "Due", Content: "Walking with @friend", AuthorID: author.ID, Draft: true, PublishedAt: nulls.NewTime(time.Now().Add(-time.Minute))}
+	later := &models.Text{Title: "Later", AuthorID: author.ID, Draft: true, PublishedAt: nulls.NewTime(time.Now().Add(time.Hour))}
+	draft := &models.Text{Title: "Draft", AuthorID: author.ID, Draft: true}
+	ms.NoError(ms.DB.Create(due))
+	ms.NoError(ms.DB.Create(later))
+	ms.NoError(ms.DB.Create(draft))
+	ms.True(due.Scheduled())
+	ms.False(draft.Scheduled())
+
+	published, err := models.PublishDueTexts(ms.DB)
+	ms.NoError(err)
+	ms.Len(published, 1)
+	ms.Equal(due.ID, published[0].ID)
+
+	ms.NoError(ms.DB.Reload(due))
+	ms.False(due.Draft)
+	ms.NoError(ms.DB.Reload(later))
+	ms.True(later.Draft)
+
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(models.PointsPosts, author.Score)
+	ms.WithinDuration(due.PublishedAt.Time, author.LastPostedAt, time.Second)
+
+	// the mentioned users are told once the text is live
+	mentions, err := ms.DB.Where("user_id = ? AND kind = ?", friend.ID, models.NotificationMentioned).Count("notifications")
+	ms.NoError(err)
+	ms.Equal(1, mentions)
+}
+
+func (ms *ModelSuite) Test_Text_PublishDueTexts_Postponed() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+
+	// already used the posting quota
+	for i := 0; i < models.Posting.Quota; i++ {
+		ms.published(author, time.Hour)
+	}
+	due := &models.Text{Title: "Due", AuthorID: author.ID, Draft: true, PublishedAt: nulls.NewTime(time.Now().Add(-time.Minute))}
+	ms.NoError(ms.DB.Create(due))
+
+	published, err := models.PublishDueTexts(ms.DB)
+	ms.NoError(err)
+	ms.Len(published, 0)
+
+	ms.NoError(ms.DB.Reload(due))
+	ms.True(due.Scheduled())
+	ms.True(due.PublishedAt.Time.After(time.Now()))
+}
+
+func (ms *ModelSuite) Test_Text_TextWentLive_FirstTimeOnly() {
+	author := &models.User{}
+	ms.NoError(ms.DB.Create(author))
+	friend := &models.User{Nickname: nulls.NewString("friend"), ProviderID: nulls.NewString("1")}
+	ms.NoError(ms.DB.Create(friend))
+
+	text := &models.Text{Title: "Loop", Content: "With @friend", AuthorID: author.ID, PublishedAt: nulls.NewTime(time.Now())}
+	ms.NoError(ms.DB.Create(text))
+	ms.NoError(models.TextWentLive(ms.DB, author, text))
+	ms.True(text.FirstPublishedAt.Valid)
+
+	// unpublished then published again: no more points, no more notifications
+	ms.NoError(models.TextWentLive(ms.DB, author, text))
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(models.PointsPosts, author.Score)
+	mentions, err := ms.DB.Where("user_id = ?", friend.ID).Count("notifications")
+	ms.NoError(err)
+	ms.Equal(1, mentions)
+
+	// deleting it takes the points back
+	ms.NoError(models.DeleteText(ms.DB, text))
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(0, author.Score)
+
+	// a draft never credited costs nothing to delete
+	draft := &models.Text{Title: "Sketch", AuthorID: author.ID, Draft: true}
+	ms.NoError(ms.DB.Create(draft))
+	ms.NoError(models.DeleteText(ms.DB, draft))
+	ms.NoError(ms.DB.Reload(author))
+	ms.Equal(0, author.Score)
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/gobuffalo/uuid"
 	"github.com/gobuffalo/validate"
 	"github.com/gobuffalo/validate/validators"
+	"github.com/pkg/errors"
 )
 
 // we have a points system, given to users
@@ -21,6 +22,7 @@ const (
 	PointsPerDayAway     = -1
 	PointsTextStarred    = 1
 	PointsTextFlagged    = -10
+	PointsComments       = 2
 )
 
 // User is the struct for our users
@@ -28,28 +30,34 @@ const (
 // when we also have a unique index on said field(s)
 // see actions/shared.go ToNullString func
 type User struct {
-	ID                uuid.UUID    `json:"id" db:"id"`
-	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at"`
-	AvatarURL         nulls.String `json:"avatar_url" db:"avatar_url"`
-	Bio               nulls.String `json:"bio" db:"bio"`
-	Email             nulls.String `json:"email" db:"email"`
-	InvitationToken   string       `json:"invitation_token" db:"invitation_token"`
-	InvitedAt         time.Time    `json:"invited_at" db:"invited_at"`
-	IsAdmin           bool         `json:"is_admin" db:"is_admin"`
-	LastLoggedAt      time.Time    `json:"last_logged_at" db:"last_logged_at"`
-	LastPostedAt      time.Time    `json:"last_posted_at" db:"last_posted_at"`
-	Name              nulls.String `json:"name" db:"name"`
-	Nickname          nulls.String `json:"nickname" db:"nickname"`
-	Provider          nulls.String `json:"provider" db:"provider"`
-	ProviderID        nulls.String `json:"provider_id" db:"provider_id"`
-	Score             int          `json:"score" db:"score"`
-	SignedUpAt        time.Time    `json:"signedup_at" db:"signedup_at"`
-	SponsorshipsCount int          `json:"sponsorships_count" db:"sponsorships_count"`
-	SponsorID         uuid.UUID    `json:"sponsor_id" db:"sponsor_id"`
-	Sponsoring        Users        `has_many:"users"`
-	Texts             Texts        `has_many:"texts" order_by:"created_at desc"`
-	Starred           Texts        `many_to_many:"stars" db:"-"`
+	ID                 uuid.UUID    `json:"id" db:"id"`
+	CreatedAt          time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at" db:"updated_at"`
+	AvatarURL          nulls.String `json:"avatar_url" db:"avatar_url"`
+	Bio                nulls.String `json:"bio" db:"bio"`
+	Email              nulls.String `json:"email" db:"email"`
+	InvitationToken    string       `json:"invitation_token" db:"invitation_token"`
+	InvitedAt          time.Time    `json:"invited_at" db:"invited_at"`
+	IsAdmin            bool         `json:"is_admin" db:"is_admin"`
+	LastLoggedAt       time.Time    `json:"last_logged_at" db:"last_logged_at"`
+	LastPostedAt       time.Time    `json:"last_posted_at" db:"last_posted_at"`
+	Name               nulls.String `json:"name" db:"name"`
+	Nickname           nulls.String `json:"nickname" db:"nickname"`
+	Provider           nulls.String `json:"provider" db:"provider"`
+	ProviderID         nulls.String `json:"provider_id" db:"provider_id"`
+	Score              int          `json:"score" db:"score"`
+	SignedUpAt         time.Time    `json:"signedup_at" db:"signedup_at"`
+	SponsorshipsCount  int          `json:"sponsorships_count" db:"sponsorships_count"`
+	SponsorID          uuid.UUID    `json:"sponsor_id" db:"sponsor_id"`
+	SponsorshipUsed    bool         `json:"sponsorship_used" db:"sponsorship_used"`
+	DigestFrequency    string       `json:"digest_frequency" db:"digest_frequency"`
+	EmailNotifications bool         `json:"email_notifications" db:"email_notifications"`
+	LastDigestAt       nulls.Time   `json:"last_digest_at" db:"last_digest_at"`
+	DeletionDueAt      nulls.Time   `json:"deletion_due_at" db:"deletion_due_at"`
+	DeletionMode       string       `json:"deletion_mode" db:"deletion_mode"`
+	Sponsoring         Users        `has_many:"users"`
+	Texts              Texts        `has_many:"texts" order_by:"created_at desc"`
+	Starred            Texts        `many_to_many:"stars" db:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -67,6 +75,15 @@ func (u Users) String() string {
 	return string(ju)
 }
 
+// BeforeCreate gives new users the weekly digest. pop writes every field,
+// so the column default never applies.
+func (u *User) BeforeCreate(tx *pop.Connection) error {
+	if u.DigestFrequency == "" {
+		u.DigestFrequency = DigestWeekly
+	}
+	return nil
+}
+
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 /*
@@ -124,12 +141,35 @@ func nickGenerate() string {
 	return suffix
 }
 
-// CanPost checks if user has already posted in last 24 hours
-func (u *User) CanPost() bool {
-	// id est : 1 post per 24 hours max.
-	diff := time.Since(u.LastPostedAt)
-	if diff.Hours() < 24.0 {
-		return false
+// CanManage checks if the user may edit or delete what belongs to ownerID:
+// her own account and texts, anything for admins
+func (u *User) CanManage(ownerID uuid.UUID) bool {
+	return u.IsAdmin || u.ID == ownerID
+}
+
+// usersByID retrieves the users with the given ids in a single query,
+// ids may repeat
+func usersByID(tx *pop.Connection, ids []uuid.UUID) (map[uuid.UUID]User, error) {
+	byID := map[uuid.UUID]User{}
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	unique := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	users := Users{}
+	if err := tx.Where("id in (?)", uuidArgs(unique)...).All(&users); err != nil {
+		return byID, errors.WithStack(err)
+	}
+	for _, u := range users {
+		byID[u.ID] = u
 	}
-	return true
+	return byID, nil
 }
@@ -1,7 +1,17 @@
 package models_test
 
-import "testing"
+import (
+	"github.com/gobuffalo/uuid"
+	"github.com/nicomo/kumano/models"
+)
 
-func Test_User(t *testing.T) {
-	t.Fatal("This test needs to be implemented!")
+func (ms *ModelSuite) Test_User_CanManage() {
+	u := &models.User{ID: uuid.Must(uuid.NewV4())}
+	someoneElse := uuid.Must(uuid.NewV4())
+
+	ms.True(u.CanManage(u.ID))
+	ms.False(u.CanManage(someoneElse))
+
+	u.IsAdmin = true
+	ms.True(u.CanManage(someoneElse))
 }